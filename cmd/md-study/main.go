@@ -1,49 +1,297 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 
 	"github.com/spf13/cobra"
+	"github.com/valdezdata/md-study/internal/cloze"
+	"github.com/valdezdata/md-study/internal/config"
+	"github.com/valdezdata/md-study/internal/generator"
 	"github.com/valdezdata/md-study/internal/processor"
 	"github.com/valdezdata/md-study/internal/scheduler"
 	"github.com/valdezdata/md-study/internal/storage"
 	"github.com/valdezdata/md-study/internal/studyengine"
 )
 
+var (
+	// cfg holds the settings loaded from the data directory
+	cfg config.Config
+	// store is the storage backend shared by all commands
+	store storage.Store
+	// storageFlag overrides the storage backend from the config file
+	storageFlag string
+)
+
+// Files generation keeps in the data directory
+const (
+	cacheDir = "cache"       // Cached model responses
+	usageLog = "usage.jsonl" // Token usage of every API call
+)
+
+// openStore loads the config and opens the configured storage backend
+func openStore() (storage.Store, error) {
+	dir, err := storage.DefaultDir()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err = config.Load(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	backend := cfg.Storage
+	if storageFlag != "" {
+		backend = storageFlag
+	}
+
+	return storage.Open(dir, backend)
+}
+
+// loadSchedulers builds the scheduler for every deck in the config
+func loadSchedulers() (scheduler.Set, error) {
+	settings := func(deck string) scheduler.Settings {
+		d := cfg.Deck(deck)
+		return scheduler.Settings{Algorithm: d.Scheduler, DesiredRetention: d.DesiredRetention}
+	}
+
+	def, err := scheduler.New(settings(""))
+	if err != nil {
+		return scheduler.Set{}, err
+	}
+
+	set := scheduler.Set{Default: def, Decks: make(map[string]scheduler.Scheduler)}
+	for name := range cfg.Decks {
+		sched, err := scheduler.New(settings(name))
+		if err != nil {
+			return scheduler.Set{}, fmt.Errorf("deck %s: %w", name, err)
+		}
+		set.Decks[name] = sched
+	}
+
+	return set, nil
+}
+
+// loadGenerators collects the generator settings for every deck in the config.
+// A provider given on the command line replaces the configured one, along with
+// its model and base URL unless they are given too.
+func loadGenerators(override generator.Settings) (*generator.Set, error) {
+	settings := func(deck string) generator.Settings {
+		d := cfg.Deck(deck)
+		s := generator.Settings{Provider: d.Provider, Model: d.Model, BaseURL: d.BaseURL, Temperature: config.DefaultTemperature, Fixtures: cfg.Fixtures, EmbeddingModel: d.EmbeddingModel}
+		if d.Temperature != nil {
+			s.Temperature = *d.Temperature
+		}
+
+		if override.Provider != "" && override.Provider != s.Provider {
+			s.Provider, s.Model, s.BaseURL, s.EmbeddingModel = override.Provider, "", "", ""
+		}
+		if override.Model != "" {
+			s.Model = override.Model
+		}
+		if override.BaseURL != "" {
+			s.BaseURL = override.BaseURL
+		}
+		if override.Fixtures != "" {
+			s.Fixtures = override.Fixtures
+		}
+		return s
+	}
+
+	decks := make(map[string]generator.Settings)
+	for name := range cfg.Decks {
+		decks[name] = settings(name)
+	}
+
+	set, err := generator.NewSet(settings(""), decks)
+	if err != nil {
+		return nil, err
+	}
+	set.RequestsPerMinute = cfg.RequestsPerMinute
+	set.Prices = make(map[string]generator.Price)
+	for model, price := range cfg.Prices {
+		set.Prices[model] = generator.Price{Input: price.Input, Output: price.Output}
+	}
+	return set, nil
+}
+
+// printNewCard shows a flashcard held out of study for review, with the
+// lines of its note it was matched to
+func printNewCard(n, total int, card processor.NewCard) {
+	fmt.Printf("[%d/%d] %s", n, total, card.Note.Filename)
+	if len(card.Section) > 0 {
+		fmt.Printf(" > %s", strings.Join(card.Section, " > "))
+	}
+	fmt.Println()
+	if card.Type == storage.TypeCloze {
+		fmt.Printf("  Cloze: %s\n", cloze.Blank(card.Question, card.ClozeIndex))
+	} else {
+		fmt.Printf("  Q: %s\n", card.Question)
+	}
+	fmt.Printf("  A: %s\n", card.Answer)
+	if card.SourceQuote != "" {
+		fmt.Printf("  Quoted: %s\n", card.SourceQuote)
+	}
+	if lines := card.SourceLines(); lines != "" {
+		fmt.Printf("  Lines %d-%d of the note:\n", card.SourceStart, card.SourceEnd)
+		for _, line := range strings.Split(lines, "\n") {
+			fmt.Printf("    %s\n", line)
+		}
+	}
+	if card.Status == storage.StatusUnverified {
+		fmt.Println("  Unverified: the answer wasn't found in the note")
+	}
+}
+
 func main() {
 	var rootCmd = &cobra.Command{
 		Use:   "md-study",
 		Short: "Study markdown files with AI-powered spaced repetition",
 		Long: `A spaced repetition system that processes your markdown notes,
 generates flashcards, and helps you study efficiently.`,
+		// Errors are printed once by main; usage is only shown for bad arguments
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			var err error
+			store, err = openStore()
+			return err
+		},
+		PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
+			return store.Close()
+		},
 	}
 
+	rootCmd.PersistentFlags().StringVar(&storageFlag, "storage", "", "storage backend to use (json or sqlite)")
+
+	var importOpts processor.ImportOptions
 	var importCmd = &cobra.Command{
 		Use:   "import [directory]",
-		Short: "Import markdown files from a directory",
+		Short: "Import markdown files from a directory and its subdirectories",
 		Args:  cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
 			dir := args[0]
-			err := processor.ImportMarkdownFiles(dir)
+			if importOpts.Deck == "" {
+				// Default the deck to the name of the imported directory
+				abs, err := filepath.Abs(dir)
+				if err != nil {
+					fmt.Printf("Error importing files: %v\n", err)
+					os.Exit(1)
+				}
+				importOpts.Deck = filepath.Base(abs)
+			}
+			summary, err := processor.ImportMarkdownFiles(store, dir, importOpts)
 			if err != nil {
 				fmt.Printf("Error importing files: %v\n", err)
 				os.Exit(1)
 			}
+			for _, skipped := range summary.SkippedFiles {
+				fmt.Printf("Skipped %s\n", skipped)
+			}
 			fmt.Printf("Successfully imported markdown files from %s\n", dir)
+			fmt.Printf("New: %d, updated: %d, unchanged: %d, skipped: %d\n",
+				summary.New, summary.Updated, summary.Unchanged, summary.Skipped)
+			if summary.CardsAdded+summary.CardsUpdated+summary.CardsRemoved > 0 {
+				fmt.Printf("Inline flashcards: %d added, %d updated, %d removed\n", summary.CardsAdded, summary.CardsUpdated, summary.CardsRemoved)
+			}
+			if summary.Moved > 0 {
+				fmt.Printf("%d notes were moved or renamed and kept their flashcards\n", summary.Moved)
+			}
+			if summary.Deleted > 0 {
+				fmt.Printf("%d notes were deleted\n", summary.Deleted)
+			}
+			if summary.Orphaned > 0 {
+				fmt.Printf("%d flashcards are orphaned and won't be studied. Run 'md-study orphans' to archive or delete them\n", summary.Orphaned)
+			}
+			if summary.Changed > 0 {
+				fmt.Printf("%d notes changed since their flashcards were generated; run 'md-study generate --changed' to refresh them\n", summary.Changed)
+			}
 		},
 	}
 
+	var generateOpts processor.GenerateOptions
+	var generatorOverride generator.Settings
+	var record bool
+	var promptFlag string
+	var noCache bool
 	var generateCmd = &cobra.Command{
 		Use:   "generate",
 		Short: "Generate flashcards from imported notes",
 		Run: func(cmd *cobra.Command, args []string) {
-			err := processor.GenerateFlashcardsForAllNotes()
+			if generateOpts.Note == "" && (generateOpts.Replace || generateOpts.Add > 0) {
+				fmt.Println("Error: --replace and --add need a note, given with --note")
+				os.Exit(1)
+			}
+			if generateOpts.Add < 0 {
+				fmt.Println("Error: --add needs a positive number of flashcards")
+				os.Exit(1)
+			}
+
+			generators, err := loadGenerators(generatorOverride)
+			if err != nil {
+				fmt.Printf("Error in generator settings: %v\n", err)
+				os.Exit(1)
+			}
+			if record {
+				generators.Record = generators.Default.Fixtures
+				if generators.Record == "" {
+					fmt.Println("Error: --record needs a fixtures directory, set with --fixtures or in the config")
+					os.Exit(1)
+				}
+			}
+			generateOpts.Generators = generators
+			if generateOpts.Workers == 0 {
+				generateOpts.Workers = cfg.Workers
+			}
+			generateOpts.TokensPerCard = cfg.TokensPerCard
+
+			dir, err := storage.DefaultDir()
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			// Replacing asks for cards the note already had, so the cached replies would only repeat them
+			if !noCache && !generateOpts.Replace {
+				generators.Cache = filepath.Join(dir, cacheDir)
+			}
+			generators.UsageLog = filepath.Join(dir, usageLog)
+
+			generateOpts.Prompts, err = processor.LoadPrompts(dir)
+			if err != nil {
+				fmt.Printf("Error loading prompt templates: %v\n", err)
+				os.Exit(1)
+			}
+			if promptFlag != "" {
+				if err := generateOpts.Prompts.SetOverride(promptFlag); err != nil {
+					fmt.Printf("Error: %v\n", err)
+					os.Exit(1)
+				}
+			}
+			generateOpts.Rejected, err = processor.LoadRejected(dir)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			// Ctrl-C cancels the notes in progress; the ones already finished stay saved
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			err = processor.GenerateFlashcardsForAllNotes(ctx, store, generateOpts)
 			if err != nil {
 				fmt.Printf("Error generating flashcards: %v\n", err)
 				os.Exit(1)
 			}
-			fmt.Println("Successfully generated flashcards from your notes")
+			if !generateOpts.DryRun {
+				fmt.Println("Successfully generated flashcards from your notes")
+			}
 		},
 	}
 
@@ -51,7 +299,12 @@ generates flashcards, and helps you study efficiently.`,
 		Use:   "study",
 		Short: "Start a study session",
 		Run: func(cmd *cobra.Command, args []string) {
-			studyengine.StartStudySession()
+			schedulers, err := loadSchedulers()
+			if err != nil {
+				fmt.Printf("Error in scheduler settings: %v\n", err)
+				os.Exit(1)
+			}
+			studyengine.StartStudySession(store, schedulers)
 		},
 	}
 
@@ -59,7 +312,7 @@ generates flashcards, and helps you study efficiently.`,
 		Use:   "stats",
 		Short: "Show study statistics",
 		Run: func(cmd *cobra.Command, args []string) {
-			scheduler.ShowStats()
+			scheduler.ShowStats(store)
 		},
 	}
 
@@ -67,7 +320,7 @@ generates flashcards, and helps you study efficiently.`,
 		Use:   "list",
 		Short: "List all flashcards",
 		Run: func(cmd *cobra.Command, args []string) {
-			err := processor.ListAllFlashcards()
+			err := processor.ListAllFlashcards(store)
 			if err != nil {
 				fmt.Printf("Error listing flashcards: %v\n", err)
 				os.Exit(1)
@@ -81,7 +334,7 @@ generates flashcards, and helps you study efficiently.`,
 		Args:  cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
 			id := args[0]
-			err := storage.DeleteFlashcard(id)
+			err := store.DeleteFlashcard(id)
 			if err != nil {
 				fmt.Printf("Error deleting flashcard: %v\n", err)
 				os.Exit(1)
@@ -99,7 +352,7 @@ generates flashcards, and helps you study efficiently.`,
 			fmt.Scanln(&response)
 
 			if response == "y" || response == "Y" {
-				err := processor.DeleteAllFlashcards()
+				err := processor.DeleteAllFlashcards(store)
 				if err != nil {
 					fmt.Printf("Error deleting flashcards: %v\n", err)
 					os.Exit(1)
@@ -111,7 +364,313 @@ generates flashcards, and helps you study efficiently.`,
 		},
 	}
 
-	rootCmd.AddCommand(importCmd, generateCmd, studyCmd, statsCmd, listCmd, deleteCmd, resetCmd)
+	importCmd.Flags().StringVar(&importOpts.Deck, "deck", "", "deck to import the notes into (default: the directory name)")
+	importCmd.Flags().StringArrayVar(&importOpts.Include, "include", nil, "only import files matching this glob (repeatable)")
+	importCmd.Flags().StringArrayVar(&importOpts.Exclude, "exclude", nil, "skip files and directories matching this glob (repeatable)")
+
+	generateCmd.Flags().BoolVar(&generateOpts.Changed, "changed", false, "regenerate flashcards for notes edited since their cards were generated")
+	generateCmd.Flags().StringVar(&generatorOverride.Provider, "provider", "", fmt.Sprintf("model provider to use instead of the configured one (%s)", strings.Join(generator.Providers(), ", ")))
+	generateCmd.Flags().StringVar(&generatorOverride.Model, "model", "", "model to use instead of the configured one")
+	generateCmd.Flags().StringVar(&generatorOverride.BaseURL, "base-url", "", "API endpoint to use instead of the configured one")
+	generateCmd.Flags().StringVar(&generatorOverride.Fixtures, "fixtures", "", "directory of recorded responses for the replay provider and --record")
+	generateCmd.Flags().BoolVar(&record, "record", false, "save every response to the fixtures directory so it can be replayed")
+	generateCmd.Flags().StringVar(&promptFlag, "prompt", "", "prompt template to use for every note: a file, or the name of a template in the prompts directory")
+	generateCmd.Flags().BoolVar(&generateOpts.DryRun, "dry-run", false, "estimate the tokens and cost of generating without calling the model")
+	generateCmd.Flags().BoolVar(&noCache, "no-cache", false, "send every request to the model, even if its response is cached")
+	generateCmd.Flags().IntVar(&generateOpts.Workers, "workers", 0, "number of notes to generate for at the same time (default from the config, 4)")
+	generateCmd.Flags().StringVar(&generateOpts.Note, "note", "", "generate for just this note, given by ID or file path")
+	generateCmd.Flags().BoolVar(&generateOpts.Replace, "replace", false, "with --note, regenerate the note's flashcards, keeping those whose question is still asked")
+	generateCmd.Flags().IntVar(&generateOpts.Add, "add", 0, "with --note, add this many flashcards to the note's, asking about other things")
+	generateCmd.MarkFlagsMutuallyExclusive("replace", "add")
+	generateCmd.MarkFlagsMutuallyExclusive("note", "changed")
+	generateCmd.Flags().BoolVar(&generateOpts.Accept, "accept", false, "put new flashcards straight into study instead of holding them for review-new")
+	generateCmd.Flags().BoolVar(&generateOpts.Verify, "verify", false, "ask the model to check cards whose answer can't be found in the note before holding them back")
+
+	var archiveOrphans, deleteOrphans bool
+	var orphansCmd = &cobra.Command{
+		Use:   "orphans",
+		Short: "Archive or delete flashcards whose source files were deleted",
+		Run: func(cmd *cobra.Command, args []string) {
+			orphans, err := processor.GetOrphanedNotes(store)
+			if err != nil {
+				fmt.Printf("Error getting orphaned flashcards: %v\n", err)
+				os.Exit(1)
+			}
+
+			if len(orphans) == 0 {
+				fmt.Println("No orphaned flashcards")
+				return
+			}
+
+			for _, orphan := range orphans {
+				fmt.Printf("%s is gone or opted out of study (%d flashcards)\n", orphan.Note.FilePath, len(orphan.Cards))
+
+				response := "k"
+				switch {
+				case archiveOrphans:
+					response = "a"
+				case deleteOrphans:
+					response = "d"
+				default:
+					fmt.Print("Archive, delete or keep its flashcards? (a/d/k): ")
+					fmt.Scanln(&response)
+				}
+
+				switch response {
+				case "a", "A":
+					err = processor.ArchiveOrphans(store, orphan)
+				case "d", "D":
+					err = processor.DeleteOrphans(store, orphan)
+				default:
+					fmt.Println("  Kept")
+					continue
+				}
+				if err != nil {
+					fmt.Printf("Error updating flashcards: %v\n", err)
+					os.Exit(1)
+				}
+			}
+		},
+	}
+	orphansCmd.Flags().BoolVar(&archiveOrphans, "archive", false, "archive all orphaned flashcards without asking")
+	orphansCmd.Flags().BoolVar(&deleteOrphans, "delete", false, "delete all orphaned flashcards without asking")
+	orphansCmd.MarkFlagsMutuallyExclusive("archive", "delete")
+
+	var unverifiedCmd = &cobra.Command{
+		Use:   "unverified",
+		Short: "Accept or delete generated flashcards whose answers weren't found in their notes",
+		Run: func(cmd *cobra.Command, args []string) {
+			cards, err := processor.GetUnverifiedCards(store)
+			if err != nil {
+				fmt.Printf("Error getting unverified flashcards: %v\n", err)
+				os.Exit(1)
+			}
+
+			if len(cards) == 0 {
+				fmt.Println("No unverified flashcards")
+				return
+			}
+
+			for i, card := range cards {
+				printNewCard(i+1, len(cards), card)
+
+				response := "k"
+				fmt.Print("Accept, delete or keep it for later? (a/d/k): ")
+				fmt.Scanln(&response)
+
+				switch response {
+				case "a", "A":
+					err = processor.AcceptCard(store, card.Flashcard)
+				case "d", "D":
+					err = store.DeleteFlashcard(card.ID)
+				default:
+					fmt.Println("  Kept")
+					continue
+				}
+				if err != nil {
+					fmt.Printf("Error updating flashcard: %v\n", err)
+					os.Exit(1)
+				}
+			}
+		},
+	}
+
+	var reviewVerify bool
+	var reviewNewCmd = &cobra.Command{
+		Use:   "review-new",
+		Short: "Accept, edit, reject or regenerate newly generated flashcards before studying them",
+		Run: func(cmd *cobra.Command, args []string) {
+			cards, err := processor.GetNewCards(store)
+			if err != nil {
+				fmt.Printf("Error getting new flashcards: %v\n", err)
+				os.Exit(1)
+			}
+
+			if len(cards) == 0 {
+				fmt.Println("No new flashcards to review")
+				return
+			}
+
+			dir, err := storage.DefaultDir()
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			opts := processor.GenerateOptions{Verify: reviewVerify}
+			if opts.Prompts, err = processor.LoadPrompts(dir); err != nil {
+				fmt.Printf("Error loading prompt templates: %v\n", err)
+				os.Exit(1)
+			}
+			if opts.Rejected, err = processor.LoadRejected(dir); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			// Generators are only set up if a card is regenerated, so reviewing needs no API key
+			var generators *generator.Set
+			reader := bufio.NewReader(os.Stdin)
+			readLine := func(prompt string) string {
+				fmt.Print(prompt)
+				line, _ := reader.ReadString('\n')
+				return strings.TrimSpace(line)
+			}
+
+			accepted, rejected := 0, 0
+			for i := 0; i < len(cards); i++ {
+				card := cards[i]
+				printNewCard(i+1, len(cards), card)
+
+				switch readLine("(a)ccept, (e)dit, (r)eject, (g)enerate again, (s)kip or (q)uit? ") {
+				case "a", "A":
+					if err = processor.AcceptCard(store, card.Flashcard); err == nil {
+						accepted++
+					}
+				case "e", "E":
+					var edited storage.Flashcard
+					if card.Type == storage.TypeCloze {
+						edited, err = processor.EditCard(store, card.Flashcard, readLine("New text, with {{c1::...}} deletions (Enter keeps it): "), "")
+					} else {
+						edited, err = processor.EditCard(store, card.Flashcard,
+							readLine("New question (Enter keeps it): "), readLine("New answer (Enter keeps it): "))
+					}
+					if err == nil {
+						if err = processor.AcceptCard(store, edited); err == nil {
+							accepted++
+						}
+					}
+				case "r", "R":
+					if err = processor.RejectCard(store, dir, card, readLine("Why? Future prompts will avoid it (Enter to skip): ")); err == nil {
+						rejected++
+					}
+				case "g", "G":
+					if generators == nil {
+						if generators, err = loadGenerators(generator.Settings{}); err == nil {
+							generators.Cache = filepath.Join(dir, cacheDir)
+							generators.UsageLog = filepath.Join(dir, usageLog)
+						}
+					}
+					var gen generator.Generator
+					if err == nil {
+						gen, err = generators.For(card.Note.Deck)
+					}
+					if err == nil {
+						var replacement processor.NewCard
+						replacement, err = processor.RegenerateCard(context.Background(), gen, store, card, opts)
+						if err == nil {
+							// Review the replacement next
+							cards[i] = replacement
+							i--
+						}
+					}
+				case "q", "Q":
+					fmt.Printf("Accepted %d and rejected %d flashcards; %d left to review\n", accepted, rejected, len(cards)-i)
+					return
+				default:
+					fmt.Println("  Skipped")
+				}
+				if err != nil {
+					fmt.Printf("  Error: %v\n", err)
+					err = nil
+				}
+			}
+			fmt.Printf("Accepted %d and rejected %d flashcards\n", accepted, rejected)
+		},
+	}
+	reviewNewCmd.Flags().BoolVar(&reviewVerify, "verify", false, "ask the model to check regenerated cards whose answer can't be found in the note")
+
+	var reportDuplicates, mergeDuplicates, useEmbeddings bool
+	dedupeOpts := processor.DedupeOptions{EmbeddingThreshold: processor.DefaultEmbeddingThreshold}
+	var dedupeCmd = &cobra.Command{
+		Use:   "dedupe",
+		Short: "Find flashcards that ask the same thing and merge them",
+		Run: func(cmd *cobra.Command, args []string) {
+			if useEmbeddings {
+				generators, err := loadGenerators(generator.Settings{})
+				if err != nil {
+					fmt.Printf("Error in generator settings: %v\n", err)
+					os.Exit(1)
+				}
+				if dir, err := storage.DefaultDir(); err == nil {
+					generators.Cache = filepath.Join(dir, cacheDir)
+					generators.UsageLog = filepath.Join(dir, usageLog)
+				}
+				dedupeOpts.Embeddings = generators
+			}
+
+			clusters, err := processor.FindDuplicates(context.Background(), store, dedupeOpts)
+			if err != nil {
+				fmt.Printf("Error finding duplicates: %v\n", err)
+				os.Exit(1)
+			}
+
+			if len(clusters) == 0 {
+				fmt.Println("No duplicate flashcards")
+				return
+			}
+
+			merged := 0
+			for n, cluster := range clusters {
+				fmt.Printf("[%d/%d] %d flashcards ask the same thing", n+1, len(clusters), len(cluster.Cards))
+				if cluster.Deck != "" {
+					fmt.Printf(" in deck %s", cluster.Deck)
+				}
+				fmt.Println(":")
+				for i, card := range cluster.Cards {
+					question := card.Question
+					if card.Type == storage.TypeCloze {
+						question = cloze.Blank(card.Question, card.ClozeIndex)
+					}
+					fmt.Printf("  %d. %s (%s, %d reviews)\n", i+1, question, card.Note.Filename, card.Reviews)
+					if card.Type == storage.TypeBasic {
+						fmt.Printf("     A: %s\n", card.Answer)
+					}
+				}
+
+				if reportDuplicates {
+					continue
+				}
+
+				response := "1"
+				if !mergeDuplicates {
+					fmt.Printf("Keep which flashcard? (1-%d, s to skip, q to quit) [1]: ", len(cluster.Cards))
+					fmt.Scanln(&response)
+				}
+
+				var keep int
+				switch {
+				case response == "q" || response == "Q":
+					fmt.Printf("Merged %d of %d clusters\n", merged, len(clusters))
+					return
+				case response == "s" || response == "S":
+					fmt.Println("  Skipped")
+					continue
+				default:
+					if _, err := fmt.Sscan(response, &keep); err != nil || keep < 1 || keep > len(cluster.Cards) {
+						fmt.Println("  Skipped: not a flashcard number")
+						continue
+					}
+				}
+
+				if err := processor.MergeDuplicates(store, cluster, keep-1); err != nil {
+					fmt.Printf("Error merging flashcards: %v\n", err)
+					os.Exit(1)
+				}
+				fmt.Printf("  Kept %d, archived the others\n", keep)
+				merged++
+			}
+
+			if !reportDuplicates {
+				fmt.Printf("Merged %d of %d clusters\n", merged, len(clusters))
+			}
+		},
+	}
+	dedupeCmd.Flags().BoolVar(&reportDuplicates, "report", false, "only list the duplicates")
+	dedupeCmd.Flags().BoolVar(&mergeDuplicates, "yes", false, "merge every cluster into its card with the most reviews without asking")
+	dedupeCmd.Flags().BoolVar(&useEmbeddings, "embeddings", false, "also compare meanings with the provider's embeddings, to find reworded questions")
+	dedupeCmd.Flags().Float64Var(&dedupeOpts.Threshold, "threshold", processor.DefaultDuplicateThreshold, "share of words two questions need in common to be duplicates, from 0 to 1")
+	dedupeCmd.MarkFlagsMutuallyExclusive("report", "yes")
+
+	rootCmd.AddCommand(importCmd, generateCmd, studyCmd, statsCmd, listCmd, deleteCmd, resetCmd, orphansCmd, unverifiedCmd, reviewNewCmd, dedupeCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
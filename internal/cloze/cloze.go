@@ -0,0 +1,171 @@
+// Package cloze parses and renders cloze deletion text, in which parts of a
+// sentence are hidden and must be recalled. Deletions are written either as
+// Anki-style "{{c1::hidden text}}", optionally with a hint as
+// "{{c1::hidden text::hint}}", or as "==highlighted text==". Each index is
+// studied as its own card; highlights are numbered after any explicit indexes.
+package cloze
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	ankiDeletion = regexp.MustCompile(`\{\{c(\d+)::(.*?)(?:::(.*?))?\}\}`)
+	ankiOpening  = regexp.MustCompile(`\{\{c\d+::`)
+	highlight    = regexp.MustCompile(`==(\S(?:[^=]*?\S)?)==`)
+	codeSpan     = regexp.MustCompile("`[^`\n]*`")
+)
+
+// Deletion is one hidden span of a cloze text
+type Deletion struct {
+	Start, End int // Byte offsets of the whole markup in the text
+	Index      int
+	Text       string // The hidden text
+	Hint       string
+}
+
+// Parse finds the deletions in text, in order. Markup inside inline code is ignored.
+func Parse(text string) []Deletion {
+	code := codeSpan.FindAllStringIndex(text, -1)
+	inCode := func(start, end int) bool {
+		for _, c := range code {
+			if start < c[1] && end > c[0] {
+				return true
+			}
+		}
+		return false
+	}
+
+	var deletions []Deletion
+	maxIndex := 0
+	for _, m := range ankiDeletions(text) {
+		if inCode(m[0], m[1]) {
+			continue
+		}
+
+		index, _ := strconv.Atoi(text[m[2]:m[3]])
+		d := Deletion{Start: m[0], End: m[1], Index: index, Text: text[m[4]:m[5]]}
+		if m[6] >= 0 {
+			d.Hint = text[m[6]:m[7]]
+		}
+		deletions = append(deletions, d)
+		maxIndex = max(maxIndex, index)
+	}
+
+	for _, m := range highlight.FindAllStringSubmatchIndex(text, -1) {
+		if inCode(m[0], m[1]) || overlaps(deletions, m[0], m[1]) {
+			continue
+		}
+		maxIndex++
+		deletions = append(deletions, Deletion{Start: m[0], End: m[1], Index: maxIndex, Text: text[m[2]:m[3]]})
+	}
+
+	sort.Slice(deletions, func(i, j int) bool {
+		return deletions[i].Start < deletions[j].Start
+	})
+	return deletions
+}
+
+// ankiDeletions returns the submatch indexes of every "{{cN::...}}" in text. A
+// deletion that is never closed is left as text, rather than running on to
+// the end of the next one.
+func ankiDeletions(text string) [][]int {
+	var matches [][]int
+	pos := 0
+	for {
+		m := ankiDeletion.FindStringSubmatchIndex(text[pos:])
+		if m == nil {
+			return matches
+		}
+		for i := range m {
+			if m[i] >= 0 {
+				m[i] += pos
+			}
+		}
+
+		// Another deletion opening inside this one means this one isn't closed
+		if inner := ankiOpening.FindStringIndex(text[m[0]+1 : m[1]]); inner != nil {
+			pos = m[0] + 1 + inner[0]
+			continue
+		}
+		matches = append(matches, m)
+		pos = m[1]
+	}
+}
+
+// Indexes returns the distinct deletion indexes in text, in ascending order
+func Indexes(text string) []int {
+	seen := make(map[int]bool)
+	var indexes []int
+	for _, d := range Parse(text) {
+		if !seen[d.Index] {
+			seen[d.Index] = true
+			indexes = append(indexes, d.Index)
+		}
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+// Answer returns the text hidden by the deletions with the given index
+func Answer(text string, index int) string {
+	var parts []string
+	for _, d := range Parse(text) {
+		if d.Index == index {
+			parts = append(parts, d.Text)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Blank renders text with the deletions of index hidden as "[...]", or as
+// "[hint]" when they have a hint, and every other deletion shown as plain text
+func Blank(text string, index int) string {
+	return render(text, func(d Deletion) string {
+		switch {
+		case d.Index != index:
+			return d.Text
+		case d.Hint != "":
+			return "[" + d.Hint + "]"
+		default:
+			return "[...]"
+		}
+	})
+}
+
+// Reveal renders text with every deletion shown as plain text, passing the
+// deletions of index through mark so they can be highlighted
+func Reveal(text string, index int, mark func(string) string) string {
+	return render(text, func(d Deletion) string {
+		if d.Index == index {
+			return mark(d.Text)
+		}
+		return d.Text
+	})
+}
+
+// render replaces each deletion's markup with the result of replace
+func render(text string, replace func(Deletion) string) string {
+	var b strings.Builder
+	last := 0
+	for _, d := range Parse(text) {
+		b.WriteString(text[last:d.Start])
+		b.WriteString(replace(d))
+		last = d.End
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// overlaps reports whether the range start-end overlaps any deletion
+func overlaps(deletions []Deletion, start, end int) bool {
+	for _, d := range deletions {
+		if start < d.End && end > d.Start {
+			return true
+		}
+	}
+	return false
+}
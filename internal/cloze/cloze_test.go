@@ -0,0 +1,114 @@
+package cloze
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		indexes []int
+		answers map[int]string // Answer for each index
+		blanks  map[int]string // Blank for each index
+	}{
+		{
+			name:    "several indexes",
+			text:    "{{c1::Paris}} is the capital of {{c2::France}}",
+			indexes: []int{1, 2},
+			answers: map[int]string{1: "Paris", 2: "France"},
+			blanks:  map[int]string{1: "[...] is the capital of France", 2: "Paris is the capital of [...]"},
+		},
+		{
+			name:    "same index twice",
+			text:    "{{c1::TCP}} and {{c1::UDP}} run over {{c2::IP}}",
+			indexes: []int{1, 2},
+			answers: map[int]string{1: "TCP, UDP", 2: "IP"},
+			blanks:  map[int]string{1: "[...] and [...] run over IP", 2: "TCP and UDP run over [...]"},
+		},
+		{
+			name:    "hint",
+			text:    "Go was released in {{c1::2009::year}}",
+			indexes: []int{1},
+			answers: map[int]string{1: "2009"},
+			blanks:  map[int]string{1: "Go was released in [year]"},
+		},
+		{
+			name:    "highlights after explicit indexes",
+			text:    "==HTTP== uses port {{c2::80}} and ==HTTPS== uses {{c1::443}}",
+			indexes: []int{1, 2, 3, 4},
+			answers: map[int]string{1: "443", 2: "80", 3: "HTTP", 4: "HTTPS"},
+			blanks:  map[int]string{3: "[...] uses port 80 and HTTPS uses 443"},
+		},
+		{
+			name:    "highlights alone",
+			text:    "==Goroutines== talk over ==channels==",
+			indexes: []int{1, 2},
+			answers: map[int]string{1: "Goroutines", 2: "channels"},
+			blanks:  map[int]string{2: "Goroutines talk over [...]"},
+		},
+		{
+			name:    "markup in code left alone",
+			text:    "Write `{{c1::x}}` or `a == b ==` to get {{c1::this}}",
+			indexes: []int{1},
+			answers: map[int]string{1: "this"},
+			blanks:  map[int]string{1: "Write `{{c1::x}}` or `a == b ==` to get [...]"},
+		},
+		{
+			name:    "unclosed deletion",
+			text:    "{{c1::never closed",
+			indexes: nil,
+			blanks:  map[int]string{1: "{{c1::never closed"},
+		},
+		{
+			name:    "unclosed deletion before a closed one",
+			text:    "{{c1::open and {{c2::closed}}",
+			indexes: []int{2},
+			answers: map[int]string{2: "closed"},
+			blanks:  map[int]string{2: "{{c1::open and [...]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Indexes(tt.text); !slices.Equal(got, tt.indexes) {
+				t.Errorf("Indexes = %v, want %v", got, tt.indexes)
+			}
+			for index, want := range tt.answers {
+				if got := Answer(tt.text, index); got != want {
+					t.Errorf("Answer(%d) = %q, want %q", index, got, want)
+				}
+			}
+			for index, want := range tt.blanks {
+				if got := Blank(tt.text, index); got != want {
+					t.Errorf("Blank(%d) = %q, want %q", index, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestReveal(t *testing.T) {
+	text := "{{c1::Paris}} is in ==France=="
+	mark := func(s string) string { return "*" + s + "*" }
+
+	if got, want := Reveal(text, 1, mark), "*Paris* is in France"; got != want {
+		t.Errorf("Reveal(1) = %q, want %q", got, want)
+	}
+	if got, want := Reveal(text, 2, mark), "Paris is in *France*"; got != want {
+		t.Errorf("Reveal(2) = %q, want %q", got, want)
+	}
+}
+
+func TestParseOffsets(t *testing.T) {
+	text := "a {{c1::b::hint}} ==c=="
+	got := Parse(text)
+	want := []Deletion{
+		{Start: 2, End: 17, Index: 1, Text: "b", Hint: "hint"},
+		{Start: 18, End: 23, Index: 2, Text: "c"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Parse = %+v, want %+v", got, want)
+	}
+}
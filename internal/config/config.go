@@ -0,0 +1,148 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const configFile = "config.json"
+
+// Generation settings used when none are set
+const (
+	DefaultTemperature       = 0.3 // Sampling temperature
+	DefaultWorkers           = 4   // Notes generated for at the same time
+	DefaultRequestsPerMinute = 60  // Calls to a provider's API a minute
+)
+
+// Config holds user settings read from config.json in the data directory
+type Config struct {
+	// Storage selects the storage backend: "json" (default) or "sqlite"
+	Storage string `json:"storage,omitempty"`
+
+	// Scheduler selects the scheduling algorithm: "sm2" (default) or "fsrs"
+	Scheduler string `json:"scheduler,omitempty"`
+
+	// DesiredRetention is the recall probability FSRS schedules reviews for;
+	// higher values mean more reviews
+	DesiredRetention float64 `json:"desired_retention,omitempty"`
+
+	// Provider selects the model provider used to generate flashcards:
+	// "openai" (default), "openai-compatible", "anthropic", "offline" or "replay"
+	Provider string `json:"provider,omitempty"`
+
+	// Model is the provider's model name; empty uses the provider's default
+	Model string `json:"model,omitempty"`
+
+	// BaseURL is the API endpoint, required for "openai-compatible" servers
+	// such as Ollama (http://localhost:11434/v1)
+	BaseURL string `json:"base_url,omitempty"`
+
+	// EmbeddingModel is the provider's model for embeddings, used to find
+	// duplicate flashcards; empty uses the provider's default
+	EmbeddingModel string `json:"embedding_model,omitempty"`
+
+	// Temperature controls how varied generated flashcards are
+	Temperature *float64 `json:"temperature,omitempty"`
+
+	// Fixtures is the directory of recorded responses the "replay" provider
+	// serves, and generate --record saves to
+	Fixtures string `json:"fixtures,omitempty"`
+
+	// Workers is how many notes are generated for at the same time
+	Workers int `json:"workers,omitempty"`
+
+	// RequestsPerMinute limits how often a provider's API is called while generating
+	RequestsPerMinute float64 `json:"requests_per_minute,omitempty"`
+
+	// TokensPerCard sets how many flashcards are generated: one for every
+	// TokensPerCard tokens of note text (about 60 by default)
+	TokensPerCard int `json:"tokens_per_card,omitempty"`
+
+	// Prices sets the price of models, in US dollars per million tokens, for
+	// models without a built-in price or to correct one
+	Prices map[string]Price `json:"prices,omitempty"`
+
+	// Decks overrides settings for individual decks, keyed by deck name
+	Decks map[string]DeckConfig `json:"decks,omitempty"`
+}
+
+// DeckConfig holds the settings that can differ between decks
+type DeckConfig struct {
+	Scheduler        string   `json:"scheduler,omitempty"`
+	DesiredRetention float64  `json:"desired_retention,omitempty"`
+	Provider         string   `json:"provider,omitempty"`
+	Model            string   `json:"model,omitempty"`
+	BaseURL          string   `json:"base_url,omitempty"`
+	EmbeddingModel   string   `json:"embedding_model,omitempty"`
+	Temperature      *float64 `json:"temperature,omitempty"`
+}
+
+// Price is what a model costs, in US dollars per million tokens
+type Price struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// Default returns the settings used when no config file exists
+func Default() Config {
+	temperature := DefaultTemperature
+	return Config{
+		Storage:           "json",
+		Scheduler:         "sm2",
+		DesiredRetention:  0.9,
+		Provider:          "openai",
+		Temperature:       &temperature,
+		Workers:           DefaultWorkers,
+		RequestsPerMinute: DefaultRequestsPerMinute,
+	}
+}
+
+// Deck returns the settings for the named deck, taking anything the deck
+// doesn't set from the top level. A deck that picks its own provider doesn't
+// inherit the top-level models and base URL, which belong to another provider.
+func (c Config) Deck(name string) DeckConfig {
+	deck := c.Decks[name]
+	if deck.Scheduler == "" {
+		deck.Scheduler = c.Scheduler
+	}
+	if deck.DesiredRetention == 0 {
+		deck.DesiredRetention = c.DesiredRetention
+	}
+	if deck.Provider == "" || deck.Provider == c.Provider {
+		deck.Provider = c.Provider
+		if deck.Model == "" {
+			deck.Model = c.Model
+		}
+		if deck.BaseURL == "" {
+			deck.BaseURL = c.BaseURL
+		}
+		if deck.EmbeddingModel == "" {
+			deck.EmbeddingModel = c.EmbeddingModel
+		}
+	}
+	if deck.Temperature == nil {
+		deck.Temperature = c.Temperature
+	}
+	return deck
+}
+
+// Load reads config.json from dir, falling back to defaults for anything unset
+func Load(dir string) (Config, error) {
+	cfg := Default()
+
+	data, err := os.ReadFile(filepath.Join(dir, configFile))
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	return cfg, nil
+}
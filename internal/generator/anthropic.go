@@ -0,0 +1,176 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	defaultAnthropicURL   = "https://api.anthropic.com"
+	defaultAnthropicModel = "claude-3-5-haiku-latest"
+	anthropicVersion      = "2023-06-01"
+	anthropicMaxTokens    = 4096
+)
+
+func init() {
+	Register(ProviderAnthropic, newAnthropic)
+}
+
+// Anthropic generates with the Anthropic messages API
+type Anthropic struct {
+	client      *http.Client
+	apiKey      string
+	baseURL     string
+	model       string
+	temperature float64
+}
+
+// newAnthropic creates a generator for the messages API, reading the key from ANTHROPIC_API_KEY
+func newAnthropic(settings Settings) (Generator, error) {
+	apiKey := os.Getenv("ANTHROPIC_API_KEY")
+	if apiKey == "" {
+		return nil, fmt.Errorf("ANTHROPIC_API_KEY environment variable not set")
+	}
+
+	baseURL := settings.BaseURL
+	if baseURL == "" {
+		baseURL = defaultAnthropicURL
+	}
+
+	model := settings.Model
+	if model == "" {
+		model = defaultAnthropicModel
+	}
+
+	return &Anthropic{
+		client:      http.DefaultClient,
+		apiKey:      apiKey,
+		baseURL:     strings.TrimSuffix(baseURL, "/"),
+		model:       model,
+		temperature: settings.Temperature,
+	}, nil
+}
+
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// anthropicTool is a tool the model can call; a schema is sent as the input of
+// a tool the model is made to call
+type anthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+type anthropicToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+type anthropicRequest struct {
+	Model       string               `json:"model"`
+	MaxTokens   int                  `json:"max_tokens"`
+	System      string               `json:"system,omitempty"`
+	Messages    []anthropicMessage   `json:"messages"`
+	Temperature float64              `json:"temperature"`
+	Tools       []anthropicTool      `json:"tools,omitempty"`
+	ToolChoice  *anthropicToolChoice `json:"tool_choice,omitempty"`
+}
+
+type anthropicResponse struct {
+	Content []struct {
+		Type  string          `json:"type"`
+		Text  string          `json:"text"`
+		Input json.RawMessage `json:"input"` // Arguments of a tool call
+	} `json:"content"`
+	Usage struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Generate sends the request as a single user message. A schema is sent as a
+// tool the model must call, and the arguments of the call are the reply.
+func (g *Anthropic) Generate(ctx context.Context, req Request) (Response, error) {
+	msgReq := anthropicRequest{
+		Model:       g.model,
+		MaxTokens:   anthropicMaxTokens,
+		System:      req.System,
+		Messages:    []anthropicMessage{{Role: "user", Content: req.Prompt}},
+		Temperature: g.temperature,
+	}
+	if req.Schema != nil {
+		msgReq.Tools = []anthropicTool{{Name: req.Schema.Name, Description: req.Schema.Description, InputSchema: req.Schema.Definition}}
+		msgReq.ToolChoice = &anthropicToolChoice{Type: "tool", Name: req.Schema.Name}
+	}
+
+	body, err := json.Marshal(msgReq)
+	if err != nil {
+		return Response{}, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, g.baseURL+"/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return Response{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("content-type", "application/json")
+	httpReq.Header.Set("x-api-key", g.apiKey)
+	httpReq.Header.Set("anthropic-version", anthropicVersion)
+
+	resp, err := g.client.Do(httpReq)
+	if err != nil {
+		return Response{}, fmt.Errorf("%s request failed: %w", ProviderAnthropic, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Response{}, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var result anthropicResponse
+	if err := json.Unmarshal(data, &result); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return Response{}, &APIError{Provider: ProviderAnthropic, StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+		}
+		return Response{}, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK || result.Error != nil {
+		message := http.StatusText(resp.StatusCode)
+		if result.Error != nil {
+			message = result.Error.Message
+		}
+		return Response{}, &APIError{Provider: ProviderAnthropic, StatusCode: resp.StatusCode, Message: message}
+	}
+
+	var text strings.Builder
+	for _, block := range result.Content {
+		switch {
+		case block.Type == "text" && req.Schema == nil:
+			text.WriteString(block.Text)
+		case block.Type == "tool_use" && req.Schema != nil:
+			text.Write(block.Input)
+		}
+	}
+
+	return Response{
+		Content: text.String(),
+		Usage: Usage{
+			InputTokens:  result.Usage.InputTokens,
+			OutputTokens: result.Usage.OutputTokens,
+		},
+	}, nil
+}
@@ -0,0 +1,112 @@
+package generator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Cache wraps a generator and keeps its responses on disk, so a request made
+// again with the same provider, model, prompts and note, such as after
+// deleting the cards, is answered without calling the API
+type Cache struct {
+	Generator Generator
+	Dir       string
+	Settings  Settings
+
+	// OnHit, if set, is called for every request answered from the cache
+	OnHit func()
+}
+
+// cacheKey identifies a request to a model by everything that affects the reply
+func cacheKey(settings Settings, req Request) string {
+	schema := ""
+	if req.Schema != nil {
+		schema = req.Schema.Name
+	}
+
+	h := sha256.New()
+	for _, part := range []string{settings.Provider, settings.Model, settings.BaseURL,
+		strconv.FormatFloat(settings.Temperature, 'g', -1, 64), schema, req.NoteHash, req.System, req.Prompt} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// path returns the file a request's response is cached in; files are spread
+// over subdirectories by the first characters of their key
+func (c *Cache) path(req Request) string {
+	key := cacheKey(c.Settings, req)
+	return filepath.Join(c.Dir, key[:2], key+".json")
+}
+
+// Has reports whether the response to a request is cached
+func (c *Cache) Has(req Request) bool {
+	_, err := os.Stat(c.path(req))
+	return err == nil
+}
+
+// Generate returns the cached response to the request, or passes it on and
+// caches the response if the request's Validate accepts it. A cached response
+// Validate rejects, such as one written before it was checked, is removed.
+func (c *Cache) Generate(ctx context.Context, req Request) (Response, error) {
+	path := c.path(req)
+
+	if data, err := os.ReadFile(path); err == nil {
+		var f fixture
+		if err := json.Unmarshal(data, &f); err == nil {
+			if req.Validate != nil && req.Validate(f.Response) != nil {
+				os.Remove(path)
+			} else {
+				if c.OnHit != nil {
+					c.OnHit()
+				}
+				return Response{Content: f.Response}, nil
+			}
+		}
+	}
+
+	resp, err := c.Generator.Generate(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+	if req.Validate != nil && req.Validate(resp.Content) != nil {
+		return resp, nil
+	}
+
+	// A response that can't be cached is still good, so failing to write it isn't an error
+	writeCacheEntry(path, fixture{System: req.System, Prompt: req.Prompt, Response: resp.Content, Usage: resp.Usage})
+	return resp, nil
+}
+
+// writeCacheEntry writes a cache entry as JSON, through a temporary file so a
+// cancelled run never leaves half an entry
+func writeCacheEntry(path string, entry any) error {
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,191 @@
+package generator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Embedder turns texts into vectors that lie close together when the texts
+// mean the same thing. Generators for providers with an embeddings API
+// implement it.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float64, Usage, error)
+}
+
+// embeddingBatch is the number of texts sent in one embeddings request
+const embeddingBatch = 256
+
+// defaultEmbeddingModels are the embedding models used by providers that have a default
+var defaultEmbeddingModels = map[string]string{
+	ProviderOpenAI: defaultOpenAIEmbeddingModel,
+}
+
+// Embedder returns an embedder for the named deck's provider, or an error if
+// the provider has no embeddings. Like generators, its requests are rate
+// limited together with the provider's other requests, retried if the API is
+// rate limited or has an error, and counted with the generators' usage. Texts
+// are sent in batches, and each text's embedding is cached if Cache is set.
+func (s *Set) Embedder(deck string) (Embedder, error) {
+	settings := s.Settings(deck)
+	if settings.EmbeddingModel == "" {
+		settings.EmbeddingModel = defaultEmbeddingModels[settings.Provider]
+	}
+
+	gen, err := New(settings)
+	if err != nil {
+		return nil, err
+	}
+	embedder, ok := gen.(Embedder)
+	if !ok {
+		return nil, fmt.Errorf("the %s provider has no embeddings API", settings.Provider)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.RequestsPerMinute > 0 {
+		limiter, ok := s.limiters[settings.Provider]
+		if !ok {
+			limiter = NewLimiter(s.RequestsPerMinute)
+			s.limiters[settings.Provider] = limiter
+		}
+		embedder = &limitedEmbedder{Embedder: embedder, limiter: limiter}
+	}
+	embedder = &retryEmbedder{Embedder: embedder, Retries: defaultRetries}
+
+	total := s.total(Settings{Provider: settings.Provider, Model: settings.EmbeddingModel})
+	embedder = &embedMeter{Embedder: embedder, set: s, total: total}
+	embedder = &batchEmbedder{Embedder: embedder, size: embeddingBatch}
+	if s.Cache != "" {
+		embedder = &embedCache{Embedder: embedder, Dir: s.Cache, Settings: settings, OnHit: func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			total.Cached++
+		}}
+	}
+	return embedder, nil
+}
+
+// batchEmbedder sends texts in batches of at most size, one request each
+type batchEmbedder struct {
+	Embedder
+	size int
+}
+
+func (e *batchEmbedder) Embed(ctx context.Context, texts []string) ([][]float64, Usage, error) {
+	var vectors [][]float64
+	var usage Usage
+	for start := 0; start < len(texts); start += e.size {
+		batch, batchUsage, err := e.Embedder.Embed(ctx, texts[start:min(start+e.size, len(texts))])
+		usage.InputTokens += batchUsage.InputTokens
+		if err != nil {
+			return nil, usage, err
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, usage, nil
+}
+
+// limitedEmbedder waits for its limiter before every request
+type limitedEmbedder struct {
+	Embedder
+	limiter *Limiter
+}
+
+func (e *limitedEmbedder) Embed(ctx context.Context, texts []string) ([][]float64, Usage, error) {
+	if err := e.limiter.Wait(ctx); err != nil {
+		return nil, Usage{}, err
+	}
+	return e.Embedder.Embed(ctx, texts)
+}
+
+// retryEmbedder sends requests again that failed with a retryable error, like Retry
+type retryEmbedder struct {
+	Embedder
+	Retries int
+}
+
+// embedding is the result of one request for embeddings
+type embedding struct {
+	vectors [][]float64
+	usage   Usage
+}
+
+func (e *retryEmbedder) Embed(ctx context.Context, texts []string) ([][]float64, Usage, error) {
+	result, err := retry(ctx, e.Retries, func() (embedding, error) {
+		vectors, usage, err := e.Embedder.Embed(ctx, texts)
+		return embedding{vectors, usage}, err
+	})
+	return result.vectors, result.usage, err
+}
+
+// embedCache keeps the embedding of each text on disk, next to the cached
+// responses, so only texts not embedded before with the same model are sent
+type embedCache struct {
+	Embedder
+	Dir      string
+	Settings Settings
+
+	// OnHit, if set, is called for every request answered from the cache
+	OnHit func()
+}
+
+// embedCacheEntry is a cached embedding
+type embedCacheEntry struct {
+	Text   string    `json:"text"`
+	Vector []float64 `json:"vector"`
+}
+
+// path returns the file a text's embedding is cached in
+func (c *embedCache) path(text string) string {
+	h := sha256.New()
+	for _, part := range []string{"embedding", c.Settings.Provider, c.Settings.EmbeddingModel, c.Settings.BaseURL, text} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	key := hex.EncodeToString(h.Sum(nil))
+	return filepath.Join(c.Dir, key[:2], key+".json")
+}
+
+// Embed returns the cached embeddings of the texts, and asks for the rest in
+// one request, caching them
+func (c *embedCache) Embed(ctx context.Context, texts []string) ([][]float64, Usage, error) {
+	vectors := make([][]float64, len(texts))
+	var missing []int
+	var missingTexts []string
+	for i, text := range texts {
+		var entry embedCacheEntry
+		if data, err := os.ReadFile(c.path(text)); err == nil && json.Unmarshal(data, &entry) == nil && entry.Text == text {
+			vectors[i] = entry.Vector
+			continue
+		}
+		missing = append(missing, i)
+		missingTexts = append(missingTexts, text)
+	}
+
+	if len(missing) == 0 {
+		if c.OnHit != nil {
+			c.OnHit()
+		}
+		return vectors, Usage{}, nil
+	}
+
+	embedded, usage, err := c.Embedder.Embed(ctx, missingTexts)
+	if err != nil {
+		return nil, usage, err
+	}
+	if len(embedded) != len(missing) {
+		return nil, usage, fmt.Errorf("got %d embeddings for %d texts", len(embedded), len(missing))
+	}
+	for k, i := range missing {
+		vectors[i] = embedded[k]
+		// An embedding that can't be cached is still good, so failing to write it isn't an error
+		writeCacheEntry(c.path(texts[i]), embedCacheEntry{Text: texts[i], Vector: embedded[k]})
+	}
+	return vectors, usage, nil
+}
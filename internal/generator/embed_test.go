@@ -0,0 +1,84 @@
+package generator
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// fakeEmbedder fails its first request as rate limited, then embeds each text
+// as its length, recording the size of every request
+type fakeEmbedder struct {
+	mu       sync.Mutex
+	requests []int
+}
+
+func (f *fakeEmbedder) Generate(context.Context, Request) (Response, error) {
+	return Response{}, nil
+}
+
+func (f *fakeEmbedder) Embed(_ context.Context, texts []string) ([][]float64, Usage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests = append(f.requests, len(texts))
+	if len(f.requests) == 1 {
+		return nil, Usage{}, &APIError{Provider: "fake-embed", StatusCode: http.StatusTooManyRequests, Message: "slow down"}
+	}
+
+	vectors := make([][]float64, len(texts))
+	for i, text := range texts {
+		vectors[i] = []float64{float64(len(text)), 1}
+	}
+	return vectors, Usage{InputTokens: len(texts)}, nil
+}
+
+func TestSetEmbedderRetriesBatchesAndCaches(t *testing.T) {
+	fake := &fakeEmbedder{}
+	Register("fake-embed", func(Settings) (Generator, error) { return fake, nil })
+
+	set, err := NewSet(Settings{Provider: "fake-embed", EmbeddingModel: "e1"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set.Cache = t.TempDir()
+	set.RequestsPerMinute = 6000
+
+	texts := make([]string, embeddingBatch+2)
+	for i := range texts {
+		texts[i] = string(make([]byte, i))
+	}
+
+	embedder, err := set.Embedder("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vectors, _, err := embedder.Embed(context.Background(), texts)
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if len(vectors) != len(texts) || vectors[embeddingBatch+1][0] != float64(embeddingBatch+1) {
+		t.Fatalf("got %d vectors, the last %v", len(vectors), vectors[len(vectors)-1])
+	}
+
+	// The rate limited batch is sent again, and the rest in a second batch
+	want := []int{embeddingBatch, embeddingBatch, 2}
+	if len(fake.requests) != len(want) || fake.requests[0] != want[0] || fake.requests[1] != want[1] || fake.requests[2] != want[2] {
+		t.Errorf("requests of %v texts, want %v", fake.requests, want)
+	}
+
+	// Only texts not seen before are sent again
+	embedder, _ = set.Embedder("")
+	if _, _, err := embedder.Embed(context.Background(), append(texts[:3:3], "new")); err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if last := fake.requests[len(fake.requests)-1]; len(fake.requests) != 4 || last != 1 {
+		t.Errorf("requests of %v texts, want one more of 1", fake.requests)
+	}
+
+	usage := set.Usage()
+	if len(usage) != 1 || usage[0].Requests != 3 || usage[0].InputTokens != embeddingBatch+3 {
+		t.Errorf("usage = %+v, want 3 requests of %d texts", usage, embeddingBatch+3)
+	}
+}
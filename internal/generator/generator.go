@@ -0,0 +1,314 @@
+// Package generator sends flashcard prompts to language model providers.
+// Providers register a factory under their name, and are chosen by name in
+// the config, per deck, or with the --provider flag.
+package generator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Names of the built-in providers
+const (
+	ProviderOpenAI           = "openai"
+	ProviderOpenAICompatible = "openai-compatible"
+	ProviderAnthropic        = "anthropic"
+	ProviderOffline          = "offline"
+	ProviderReplay           = "replay"
+)
+
+// Request is a single prompt for a model
+type Request struct {
+	System string // Instructions for the model
+	Prompt string
+
+	// Schema, if set, asks for a JSON reply in this form instead of free text
+	Schema *Schema
+
+	// What the prompt asks about, for generators that work without a model
+	Source  string   // Markdown the flashcards should come from
+	Section []string // Heading path of the source in its note
+	Count   int      // Number of flashcards asked for
+
+	// NoteHash is the content hash of the note the prompt comes from, for caching
+	NoteHash string
+
+	// Validate, if set, checks that a reply can be used; replies it rejects are
+	// never cached, so asking again gets a new one
+	Validate func(content string) error
+}
+
+// Usage counts the tokens a request used
+type Usage struct {
+	InputTokens  int
+	OutputTokens int
+}
+
+// Response is a model's reply to a request
+type Response struct {
+	Content string
+	Usage   Usage
+}
+
+// Generator sends prompts to a model
+type Generator interface {
+	Generate(ctx context.Context, req Request) (Response, error)
+}
+
+// Settings selects a provider and model
+type Settings struct {
+	Provider    string
+	Model       string  // Empty for the provider's default
+	BaseURL     string  // Endpoint of the API, for self-hosted and compatible servers
+	Temperature float64 // Lower values give more consistent output
+	Fixtures    string  // Directory of recorded responses, for the replay provider
+
+	// EmbeddingModel is the model used for embeddings, by providers that have
+	// them; empty for the provider's default
+	EmbeddingModel string
+}
+
+// Factory creates a generator for a provider
+type Factory func(settings Settings) (Generator, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes a provider available under name, replacing any provider registered with it before
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = factory
+}
+
+// Providers returns the names of all registered providers, sorted
+func Providers() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookup returns the factory for a provider
+func lookup(name string) (Factory, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	factory, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown provider: %s (available: %v)", name, Providers())
+	}
+	return factory, nil
+}
+
+// New returns a generator for the provider named in settings
+func New(settings Settings) (Generator, error) {
+	factory, err := lookup(settings.Provider)
+	if err != nil {
+		return nil, err
+	}
+	return factory(settings)
+}
+
+// APIError is an error response from a provider's API
+type APIError struct {
+	Provider   string
+	StatusCode int // HTTP status, or 0 if unknown
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("%s API error: %s", e.Provider, e.Message)
+	}
+	return fmt.Sprintf("%s API error (status %d): %s", e.Provider, e.StatusCode, e.Message)
+}
+
+// Set holds the generator settings for each deck. Generators are created the
+// first time a deck needs one, so providers that are never used don't need
+// credentials. A Set is safe for concurrent use.
+type Set struct {
+	Default Settings
+	Decks   map[string]Settings
+
+	// Record, if set, saves every response to this directory as a fixture for the replay provider
+	Record string
+
+	// RequestsPerMinute limits how often each provider's API is called; 0 for no limit
+	RequestsPerMinute float64
+
+	// Cache, if set, is the directory responses are cached in
+	Cache string
+
+	// UsageLog, if set, is a file every API call's token usage is appended to, one JSON object a line
+	UsageLog string
+
+	// Prices adds to or overrides the prices of models in Prices
+	Prices map[string]Price
+
+	mu         sync.Mutex
+	generators map[Settings]Generator
+	limiters   map[string]*Limiter
+	totals     map[Settings]*UsageTotal
+}
+
+// defaultModels are the models used by providers that have a default
+var defaultModels = map[string]string{
+	ProviderOpenAI:    defaultOpenAIModel,
+	ProviderAnthropic: defaultAnthropicModel,
+}
+
+// NewSet returns a set of generator settings, checking that every provider
+// exists and filling in the default model of providers that have one
+func NewSet(def Settings, decks map[string]Settings) (*Set, error) {
+	if _, err := lookup(def.Provider); err != nil {
+		return nil, err
+	}
+	if def.Model == "" {
+		def.Model = defaultModels[def.Provider]
+	}
+	for name, settings := range decks {
+		if _, err := lookup(settings.Provider); err != nil {
+			return nil, fmt.Errorf("deck %s: %w", name, err)
+		}
+		if settings.Model == "" {
+			settings.Model = defaultModels[settings.Provider]
+			decks[name] = settings
+		}
+	}
+
+	return &Set{
+		Default:    def,
+		Decks:      decks,
+		generators: make(map[Settings]Generator),
+		limiters:   make(map[string]*Limiter),
+		totals:     make(map[Settings]*UsageTotal),
+	}, nil
+}
+
+// local reports whether a provider runs without calling an API, so its
+// requests are neither limited, retried, cached nor counted
+func local(provider string) bool {
+	return provider == ProviderOffline || provider == ProviderReplay
+}
+
+// Settings returns the settings for the named deck
+func (s *Set) Settings(deck string) Settings {
+	if settings, ok := s.Decks[deck]; ok {
+		return settings
+	}
+	return s.Default
+}
+
+// For returns the generator for the named deck; decks with the same settings
+// share one. Requests to a provider's API are rate limited together, whatever
+// deck they are for, and retried if the API is rate limited or has an error.
+// Their token usage is added up, and their responses are cached if Cache is set.
+func (s *Set) For(deck string) (Generator, error) {
+	settings := s.Settings(deck)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if gen, ok := s.generators[settings]; ok {
+		return gen, nil
+	}
+
+	gen, err := New(settings)
+	if err != nil {
+		return nil, err
+	}
+	if !local(settings.Provider) {
+		if s.RequestsPerMinute > 0 {
+			limiter, ok := s.limiters[settings.Provider]
+			if !ok {
+				limiter = NewLimiter(s.RequestsPerMinute)
+				s.limiters[settings.Provider] = limiter
+			}
+			gen = &limited{Generator: gen, limiter: limiter}
+		}
+		gen = &Retry{Generator: gen, Retries: defaultRetries}
+
+		total := s.total(settings)
+		gen = &meter{Generator: gen, set: s, total: total}
+		if s.Cache != "" {
+			gen = &Cache{Generator: gen, Dir: s.Cache, Settings: settings, OnHit: func() {
+				s.mu.Lock()
+				defer s.mu.Unlock()
+				total.Cached++
+			}}
+		}
+	}
+	if s.Record != "" {
+		gen = &Recorder{Generator: gen, Dir: s.Record}
+	}
+	s.generators[settings] = gen
+	return gen, nil
+}
+
+// total returns the usage total for settings, creating it if needed; s.mu must be held
+func (s *Set) total(settings Settings) *UsageTotal {
+	total, ok := s.totals[settings]
+	if !ok {
+		total = &UsageTotal{Provider: settings.Provider, Model: settings.Model}
+		s.totals[settings] = total
+	}
+	return total
+}
+
+// Usage returns the requests and tokens used so far with each provider and
+// model, sorted by provider and model
+func (s *Set) Usage() []UsageTotal {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	merged := make(map[[2]string]*UsageTotal)
+	var totals []UsageTotal
+	for _, t := range s.totals {
+		key := [2]string{t.Provider, t.Model}
+		if m, ok := merged[key]; ok {
+			m.Requests += t.Requests
+			m.Cached += t.Cached
+			m.InputTokens += t.InputTokens
+			m.OutputTokens += t.OutputTokens
+			continue
+		}
+		copied := *t
+		merged[key] = &copied
+	}
+	for _, t := range merged {
+		totals = append(totals, *t)
+	}
+
+	sort.Slice(totals, func(i, j int) bool {
+		if totals[i].Provider != totals[j].Provider {
+			return totals[i].Provider < totals[j].Provider
+		}
+		return totals[i].Model < totals[j].Model
+	})
+	return totals
+}
+
+// Price returns the price of the model in settings, if it is known
+func (s *Set) Price(settings Settings) (Price, bool) {
+	return PriceOf(settings.Model, s.Prices)
+}
+
+// Cached reports whether the response to a request for the named deck is
+// already cached, so sending it would cost nothing
+func (s *Set) Cached(deck string, req Request) bool {
+	settings := s.Settings(deck)
+	if s.Cache == "" || local(settings.Provider) {
+		return false
+	}
+	return (&Cache{Dir: s.Cache, Settings: settings}).Has(req)
+}
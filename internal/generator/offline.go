@@ -0,0 +1,180 @@
+package generator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+func init() {
+	Register(ProviderOffline, newOffline)
+}
+
+var (
+	offlineHeading = regexp.MustCompile(`^ {0,3}#{1,6}(?:\s|$)`)
+	offlineFence   = regexp.MustCompile("^ {0,3}(```|~~~)")
+	offlineList    = regexp.MustCompile(`^\s*(?:[-*+]|\d{1,9}[.)])\s+`)
+
+	// "**Term**: definition" or "Term: definition", as in glossary lists
+	termDefinition = regexp.MustCompile(`^(?:\*\*|__)?([^*_:]{1,60}?)(?:\*\*|__)?\s*(?::|\s[-–—])\s+(.{3,})$`)
+	// "A term is a ...", "Terms are the ...", "A term means ..."
+	isDefinition = regexp.MustCompile(`^(.{1,60}?)\s+(is|are|means|refers to)\s+(.{3,})$`)
+	articles     = regexp.MustCompile(`(?i)^(?:a|an|the)\s+`)
+	sentenceEnd  = regexp.MustCompile(`[.!?](?:\s+|$)`)
+	markup       = regexp.MustCompile("[*_`]+")
+)
+
+// Offline generates flashcards from the source text with fixed rules instead
+// of a model: a card for each definition-like sentence, then a card asking
+// what the section says, answered with its first sentence not already used.
+// It needs no network and always gives the same cards for the same text.
+type Offline struct{}
+
+// newOffline creates the rule-based generator; it takes no settings
+func newOffline(Settings) (Generator, error) {
+	return Offline{}, nil
+}
+
+// Generate writes up to req.Count cards, as JSON if the request has a schema
+// and otherwise in the Q:/A: format models are asked for
+func (Offline) Generate(ctx context.Context, req Request) (Response, error) {
+	var cards []Card
+	seen := make(map[string]bool)
+	add := func(question, answer, quote string) {
+		if (req.Count > 0 && len(cards) >= req.Count) || seen[strings.ToLower(question)] {
+			return
+		}
+		seen[strings.ToLower(question)] = true
+		cards = append(cards, Card{Type: CardBasic, Question: question, Answer: answer, Tags: []string{}, SourceQuote: quote})
+	}
+
+	sentences := offlineSentences(req.Source)
+	used := make(map[string]bool)
+
+	for _, sentence := range sentences {
+		if question, answer, ok := definitionQuestion(sentence); ok {
+			add(question, answer, sentence)
+			used[sentence] = true
+		}
+	}
+
+	if len(req.Section) > 0 {
+		for _, sentence := range sentences {
+			if used[sentence] {
+				continue
+			}
+
+			heading := req.Section[len(req.Section)-1]
+			if len(req.Section) > 1 {
+				add(fmt.Sprintf("What do your notes say about %s (%s)?", heading, strings.Join(req.Section[:len(req.Section)-1], " > ")), sentence, sentence)
+			} else {
+				add(fmt.Sprintf("What do your notes say about %s?", heading), sentence, sentence)
+			}
+			break
+		}
+	}
+
+	if req.Schema != nil {
+		if cards == nil {
+			cards = []Card{}
+		}
+		data, err := json.Marshal(cardList{Cards: cards})
+		if err != nil {
+			return Response{}, fmt.Errorf("failed to encode cards: %w", err)
+		}
+		return Response{Content: string(data)}, nil
+	}
+
+	var out strings.Builder
+	for _, card := range cards {
+		fmt.Fprintf(&out, "Q: %s\nA: %s\n\n", card.Question, card.Answer)
+	}
+	return Response{Content: strings.TrimSpace(out.String())}, nil
+}
+
+// offlineSentences returns the prose of a markdown text as sentences, leaving
+// out headings, code blocks and tables; list items are kept whole
+func offlineSentences(source string) []string {
+	var sentences []string
+	var paragraph []string
+	flush := func() {
+		text := strings.Join(paragraph, " ")
+		paragraph = nil
+		for text != "" {
+			loc := sentenceEnd.FindStringIndex(text)
+			if loc == nil {
+				sentences = append(sentences, strings.TrimSpace(text))
+				break
+			}
+			sentences = append(sentences, strings.TrimSpace(text[:loc[0]+1]))
+			text = text[loc[1]:]
+		}
+	}
+
+	inCode := false
+	for _, line := range strings.Split(source, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case offlineFence.MatchString(line):
+			flush()
+			inCode = !inCode
+		case inCode:
+		case trimmed == "" || offlineHeading.MatchString(line) || strings.HasPrefix(trimmed, "|"):
+			flush()
+		case offlineList.MatchString(line):
+			flush()
+			sentences = append(sentences, strings.TrimSpace(markup.ReplaceAllString(offlineList.ReplaceAllString(line, ""), "")))
+		default:
+			paragraph = append(paragraph, markup.ReplaceAllString(trimmed, ""))
+		}
+	}
+	flush()
+
+	return sentences
+}
+
+// definitionQuestion turns a sentence that defines a term into a question
+// about it; "is" sentences are answered whole, glossary entries with their definition
+func definitionQuestion(sentence string) (question, answer string, ok bool) {
+	trimmed := strings.TrimRight(sentence, ".")
+
+	if m := isDefinition.FindStringSubmatch(trimmed); m != nil {
+		term := articles.ReplaceAllString(strings.TrimSpace(m[1]), "")
+		if !isTerm(term) {
+			return "", "", false
+		}
+		switch m[2] {
+		case "are":
+			return fmt.Sprintf("What are %s?", term), sentence, true
+		case "means", "refers to":
+			return fmt.Sprintf("What does %s mean?", term), sentence, true
+		default:
+			return fmt.Sprintf("What is %s?", term), sentence, true
+		}
+	}
+
+	if m := termDefinition.FindStringSubmatch(sentence); m != nil {
+		term := strings.TrimSpace(m[1])
+		if isTerm(term) {
+			return fmt.Sprintf("What is %s?", term), strings.TrimSpace(m[2]), true
+		}
+	}
+
+	return "", "", false
+}
+
+// isTerm reports whether text looks like a term rather than the start of a
+// longer sentence: a few words, not starting with a pronoun
+func isTerm(text string) bool {
+	words := strings.Fields(text)
+	if len(words) == 0 || len(words) > 5 {
+		return false
+	}
+	switch strings.ToLower(words[0]) {
+	case "it", "this", "that", "these", "those", "there", "he", "she", "they", "we", "you", "i", "which", "what", "why", "how", "note":
+		return false
+	}
+	return true
+}
@@ -0,0 +1,201 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync/atomic"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// defaultOpenAIModel is used when no model is configured for the openai provider
+const defaultOpenAIModel = "gpt-4.1-nano"
+
+// defaultOpenAIEmbeddingModel is used for embeddings when no embedding model is configured
+const defaultOpenAIEmbeddingModel = "text-embedding-3-small"
+
+func init() {
+	Register(ProviderOpenAI, newOpenAI)
+	Register(ProviderOpenAICompatible, newOpenAICompatible)
+}
+
+// OpenAI generates with the OpenAI chat completions API, or any server that
+// implements it
+type OpenAI struct {
+	client         *openai.Client
+	provider       string
+	model          string
+	embeddingModel string
+	temperature    float64
+
+	// noSchema is set once a compatible server has rejected a JSON schema
+	// response format, so later requests are sent without one
+	noSchema atomic.Bool
+}
+
+// newOpenAI creates a generator for the OpenAI API, reading the key from OPENAI_API_KEY
+func newOpenAI(settings Settings) (Generator, error) {
+	apiKey := os.Getenv("OPENAI_API_KEY")
+	if apiKey == "" {
+		return nil, fmt.Errorf("OPENAI_API_KEY environment variable not set")
+	}
+
+	config := openai.DefaultConfig(apiKey)
+	if settings.BaseURL != "" {
+		config.BaseURL = settings.BaseURL
+	}
+
+	model := settings.Model
+	if model == "" {
+		model = defaultOpenAIModel
+	}
+
+	embeddingModel := settings.EmbeddingModel
+	if embeddingModel == "" {
+		embeddingModel = defaultOpenAIEmbeddingModel
+	}
+
+	return &OpenAI{
+		client:         openai.NewClientWithConfig(config),
+		provider:       ProviderOpenAI,
+		model:          model,
+		embeddingModel: embeddingModel,
+		temperature:    settings.Temperature,
+	}, nil
+}
+
+// newOpenAICompatible creates a generator for a local or self-hosted server
+// with an OpenAI-compatible API, such as Ollama, llama.cpp's server or vLLM.
+// The base URL and model are required; OPENAI_API_KEY is sent if it is set,
+// since most local servers don't check it.
+func newOpenAICompatible(settings Settings) (Generator, error) {
+	if settings.BaseURL == "" {
+		return nil, fmt.Errorf("the %s provider needs a base URL, e.g. http://localhost:11434/v1 for Ollama", ProviderOpenAICompatible)
+	}
+	if settings.Model == "" {
+		return nil, fmt.Errorf("the %s provider needs a model", ProviderOpenAICompatible)
+	}
+
+	config := openai.DefaultConfig(os.Getenv("OPENAI_API_KEY"))
+	config.BaseURL = settings.BaseURL
+
+	return &OpenAI{
+		client:         openai.NewClientWithConfig(config),
+		provider:       ProviderOpenAICompatible,
+		model:          settings.Model,
+		embeddingModel: settings.EmbeddingModel,
+		temperature:    settings.Temperature,
+	}, nil
+}
+
+// Generate sends the request as a chat completion, with a JSON schema as its
+// response format if the request has one. Many OpenAI-compatible servers
+// don't support schemas and reject the request, so for them it is sent again
+// without one, and the reply is read as the model wrote it.
+func (g *OpenAI) Generate(ctx context.Context, req Request) (Response, error) {
+	var messages []openai.ChatCompletionMessage
+	if req.System != "" {
+		messages = append(messages, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleSystem, Content: req.System})
+	}
+	messages = append(messages, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: req.Prompt})
+
+	chatReq := openai.ChatCompletionRequest{
+		Model:       g.model,
+		Messages:    messages,
+		Temperature: float32(g.temperature),
+	}
+	if req.Schema != nil && !g.noSchema.Load() {
+		chatReq.ResponseFormat = &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+			JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+				Name:        req.Schema.Name,
+				Description: req.Schema.Description,
+				Schema:      req.Schema.Definition,
+				Strict:      true,
+			},
+		}
+	}
+
+	resp, err := g.client.CreateChatCompletion(ctx, chatReq)
+	if err != nil && chatReq.ResponseFormat != nil && g.provider == ProviderOpenAICompatible && rejectedRequest(g.wrapError(err)) {
+		g.noSchema.Store(true)
+		chatReq.ResponseFormat = nil
+		resp, err = g.client.CreateChatCompletion(ctx, chatReq)
+	}
+	if err != nil {
+		return Response{}, g.wrapError(err)
+	}
+
+	if len(resp.Choices) == 0 {
+		return Response{}, &APIError{Provider: g.provider, Message: "response has no choices"}
+	}
+
+	return Response{
+		Content: resp.Choices[0].Message.Content,
+		Usage: Usage{
+			InputTokens:  resp.Usage.PromptTokens,
+			OutputTokens: resp.Usage.CompletionTokens,
+		},
+	}, nil
+}
+
+// Embed returns the embeddings of texts, in one request
+func (g *OpenAI) Embed(ctx context.Context, texts []string) ([][]float64, Usage, error) {
+	if g.embeddingModel == "" {
+		return nil, Usage{}, fmt.Errorf("the %s provider needs an embedding model for embeddings; set embedding_model in the config", g.provider)
+	}
+
+	resp, err := g.client.CreateEmbeddings(ctx, openai.EmbeddingRequest{
+		Input: texts,
+		Model: openai.EmbeddingModel(g.embeddingModel),
+	})
+	if err != nil {
+		return nil, Usage{}, g.wrapError(err)
+	}
+	usage := Usage{InputTokens: resp.Usage.PromptTokens}
+
+	vectors := make([][]float64, len(texts))
+	for _, e := range resp.Data {
+		if e.Index < 0 || e.Index >= len(texts) {
+			return nil, usage, &APIError{Provider: g.provider, Message: "embedding index out of range"}
+		}
+		vector := make([]float64, len(e.Embedding))
+		for i, x := range e.Embedding {
+			vector[i] = float64(x)
+		}
+		vectors[e.Index] = vector
+	}
+
+	for i, vector := range vectors {
+		if vector == nil {
+			return nil, usage, &APIError{Provider: g.provider, Message: fmt.Sprintf("no embedding returned for text %d", i+1)}
+		}
+	}
+	return vectors, usage, nil
+}
+
+// rejectedRequest reports whether the server refused a request as invalid,
+// as servers without structured output do when given a schema
+func rejectedRequest(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) &&
+		(apiErr.StatusCode == http.StatusBadRequest || apiErr.StatusCode == http.StatusUnprocessableEntity)
+}
+
+// wrapError turns the client's errors into an APIError with the HTTP status
+func (g *OpenAI) wrapError(err error) error {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return &APIError{Provider: g.provider, StatusCode: apiErr.HTTPStatusCode, Message: apiErr.Message}
+	}
+
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return &APIError{Provider: g.provider, StatusCode: reqErr.HTTPStatusCode, Message: reqErr.Error()}
+	}
+
+	return fmt.Errorf("%s request failed: %w", g.provider, err)
+}
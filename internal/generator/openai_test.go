@@ -0,0 +1,50 @@
+package generator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOpenAICompatibleWithoutSchema(t *testing.T) {
+	var withSchema, withoutSchema int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if _, ok := body["response_format"]; ok {
+			withSchema++
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": {"message": "response_format is not supported", "type": "invalid_request_error"}}`))
+			return
+		}
+		withoutSchema++
+		w.Write([]byte(`{"choices": [{"index": 0, "message": {"role": "assistant", "content": "Q: What port does HTTPS use?\nA: 443"}}]}`))
+	}))
+	defer server.Close()
+
+	gen, err := New(Settings{Provider: ProviderOpenAICompatible, BaseURL: server.URL, Model: "local"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := Request{Prompt: "Write a card.", Schema: &CardSchema}
+	for range 2 {
+		resp, err := gen.Generate(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Generate: %v", err)
+		}
+		if resp.Content != "Q: What port does HTTPS use?\nA: 443" {
+			t.Errorf("Content = %q", resp.Content)
+		}
+	}
+
+	// The schema is only tried once; the second request is sent without it
+	if withSchema != 1 || withoutSchema != 2 {
+		t.Errorf("requests with schema %d, without %d, want 1 and 2", withSchema, withoutSchema)
+	}
+}
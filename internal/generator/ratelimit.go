@@ -0,0 +1,62 @@
+package generator
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket that spaces requests out to a steady rate. The
+// bucket holds a second's worth of requests, and at least one, so a few can
+// start at once.
+type Limiter struct {
+	mu     sync.Mutex
+	rate   float64 // Tokens added per second
+	burst  float64 // Most tokens the bucket holds
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter returns a limiter allowing perMinute requests a minute
+func NewLimiter(perMinute float64) *Limiter {
+	burst := max(1, float64(int(perMinute/60)))
+	return &Limiter{rate: perMinute / 60, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// Wait blocks until a request may be made, or ctx is cancelled
+func (l *Limiter) Wait(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+		l.last = now
+		if l.tokens >= 1 {
+			l.tokens--
+			l.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		l.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// limited waits for its limiter before every request
+type limited struct {
+	Generator
+	limiter *Limiter
+}
+
+func (g *limited) Generate(ctx context.Context, req Request) (Response, error) {
+	if err := g.limiter.Wait(ctx); err != nil {
+		return Response{}, err
+	}
+	return g.Generator.Generate(ctx, req)
+}
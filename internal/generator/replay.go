@@ -0,0 +1,92 @@
+package generator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+func init() {
+	Register(ProviderReplay, newReplay)
+}
+
+// fixture is a recorded response, stored as <key>.json in a fixtures directory
+type fixture struct {
+	System   string `json:"system"`
+	Prompt   string `json:"prompt"`
+	Response string `json:"response"`
+	Usage    Usage  `json:"usage"`
+}
+
+// fixtureKey identifies a request by its prompts, so a response recorded with
+// one provider can be replayed whatever provider or model made it
+func fixtureKey(req Request) string {
+	sum := sha256.Sum256([]byte(req.System + "\x00" + req.Prompt))
+	return hex.EncodeToString(sum[:])[:32]
+}
+
+// Replay serves responses recorded earlier, so generation can run without a
+// network connection or API key and always gives the same result
+type Replay struct {
+	dir string
+}
+
+// newReplay creates a generator that reads fixtures from settings.Fixtures
+func newReplay(settings Settings) (Generator, error) {
+	if settings.Fixtures == "" {
+		return nil, fmt.Errorf("the %s provider needs a fixtures directory", ProviderReplay)
+	}
+	return &Replay{dir: settings.Fixtures}, nil
+}
+
+// Generate returns the recorded response for the request
+func (g *Replay) Generate(ctx context.Context, req Request) (Response, error) {
+	key := fixtureKey(req)
+
+	data, err := os.ReadFile(filepath.Join(g.dir, key+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return Response{}, fmt.Errorf("no recorded response for this prompt (fixture %s); record one with generate --record", key)
+	}
+	if err != nil {
+		return Response{}, fmt.Errorf("failed to read fixture: %w", err)
+	}
+
+	var f fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return Response{}, fmt.Errorf("failed to parse fixture %s: %w", key, err)
+	}
+
+	return Response{Content: f.Response, Usage: f.Usage}, nil
+}
+
+// Recorder wraps a generator and saves each of its responses as a fixture
+type Recorder struct {
+	Generator Generator
+	Dir       string
+}
+
+// Generate passes the request on and records the response
+func (r *Recorder) Generate(ctx context.Context, req Request) (Response, error) {
+	resp, err := r.Generator.Generate(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+
+	data, err := json.MarshalIndent(fixture{System: req.System, Prompt: req.Prompt, Response: resp.Content, Usage: resp.Usage}, "", "  ")
+	if err != nil {
+		return resp, fmt.Errorf("failed to encode fixture: %w", err)
+	}
+	if err := os.MkdirAll(r.Dir, 0755); err != nil {
+		return resp, fmt.Errorf("failed to create fixtures directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(r.Dir, fixtureKey(req)+".json"), data, 0644); err != nil {
+		return resp, fmt.Errorf("failed to write fixture: %w", err)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,66 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// Retries a Set makes of a failed request, and the wait before the first of
+// them, which doubles after each one up to maxBackoff
+const (
+	defaultRetries = 4
+	firstBackoff   = time.Second
+	maxBackoff     = 30 * time.Second
+)
+
+// Retryable reports whether a request that failed with err may succeed if sent
+// again: the API was rate limited or had a server error
+func Retryable(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500
+}
+
+// Retry wraps a generator and sends requests again that failed with a
+// retryable error, waiting exponentially longer, with jitter, between attempts
+type Retry struct {
+	Generator Generator
+	Retries   int // Attempts after the first
+}
+
+// Generate sends the request until it succeeds, fails for good, runs out of
+// retries or ctx is cancelled
+func (r *Retry) Generate(ctx context.Context, req Request) (Response, error) {
+	return retry(ctx, r.Retries, func() (Response, error) {
+		return r.Generator.Generate(ctx, req)
+	})
+}
+
+// retry makes a call until it succeeds, fails for good, runs out of retries or
+// ctx is cancelled
+func retry[T any](ctx context.Context, retries int, call func() (T, error)) (T, error) {
+	backoff := firstBackoff
+	for attempt := 0; ; attempt++ {
+		result, err := call()
+		if err == nil || attempt >= retries || !Retryable(err) || ctx.Err() != nil {
+			return result, err
+		}
+
+		// Between half and all of the backoff, so parallel requests don't retry in step
+		wait := backoff/2 + rand.N(backoff/2+1)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			var zero T
+			return zero, ctx.Err()
+		case <-timer.C:
+		}
+		backoff = min(2*backoff, maxBackoff)
+	}
+}
@@ -0,0 +1,187 @@
+package generator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/valdezdata/md-study/internal/cloze"
+)
+
+// Schema describes the JSON a model must reply with. Providers that support
+// structured output enforce it; the reply is still checked with ParseCards.
+type Schema struct {
+	Name        string
+	Description string
+	Definition  json.RawMessage // JSON Schema of the reply
+}
+
+// Card types a model can reply with
+const (
+	CardBasic = "basic"
+	CardCloze = "cloze"
+)
+
+// Card is a flashcard as a model writes it
+type Card struct {
+	Type        string   `json:"type"`
+	Question    string   `json:"question"` // For cloze cards, the text with {{c1::...}} deletions
+	Answer      string   `json:"answer"`   // Empty for cloze cards
+	Tags        []string `json:"tags"`
+	SourceQuote string   `json:"source_quote"` // Passage of the source the card is based on, word for word
+}
+
+// cardList is the object a model replies with
+type cardList struct {
+	Cards []Card `json:"cards"`
+}
+
+// CardSchema asks for a list of flashcards. It follows the rules of OpenAI's
+// strict mode: every property is required and no others are allowed.
+var CardSchema = Schema{
+	Name:        "flashcards",
+	Description: "Flashcards created from the notes",
+	Definition: json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "cards": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {
+          "type": {"type": "string", "enum": ["basic", "cloze"], "description": "basic for a question and answer, cloze for a sentence with hidden parts"},
+          "question": {"type": "string", "description": "The question, or for cloze cards the sentence with each hidden part written as {{c1::hidden part}}"},
+          "answer": {"type": "string", "description": "The answer; empty for cloze cards"},
+          "tags": {"type": "array", "items": {"type": "string"}, "description": "Topics of the card, as short lowercase words"},
+          "source_quote": {"type": "string", "description": "The sentence or passage of the notes the card is based on, quoted word for word"}
+        },
+        "required": ["type", "question", "answer", "tags", "source_quote"],
+        "additionalProperties": false
+      }
+    }
+  },
+  "required": ["cards"],
+  "additionalProperties": false
+}`),
+}
+
+// ParseCards reads a reply written to CardSchema, checking that it is a single
+// JSON object with only the expected fields and that every card is complete.
+// A code fence around the JSON is allowed, since some models add one anyway.
+func ParseCards(content string) ([]Card, error) {
+	var list cardList
+	if err := decodeReply(content, &list); err != nil {
+		return nil, err
+	}
+	if list.Cards == nil {
+		return nil, fmt.Errorf(`response has no "cards" list`)
+	}
+
+	var problems []string
+	for i, card := range list.Cards {
+		if err := validateCard(card); err != nil {
+			problems = append(problems, fmt.Sprintf("card %d: %v", i+1, err))
+		}
+	}
+	if len(problems) > 0 {
+		return nil, errors.New(strings.Join(problems, "; "))
+	}
+
+	return list.Cards, nil
+}
+
+// decodeReply reads a reply as a single JSON object with only the fields of v,
+// allowing a code fence around it
+func decodeReply(content string, v any) error {
+	content = strings.TrimSpace(content)
+	if strings.HasPrefix(content, "```") {
+		content = strings.TrimPrefix(content, "```json")
+		content = strings.TrimPrefix(content, "```")
+		content = strings.TrimSpace(strings.TrimSuffix(content, "```"))
+	}
+
+	dec := json.NewDecoder(strings.NewReader(content))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("response is not valid JSON for the schema: %w", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return fmt.Errorf("response has more than one JSON value")
+	}
+	return nil
+}
+
+// validateCard checks that a card has what its type needs
+func validateCard(card Card) error {
+	if strings.TrimSpace(card.Question) == "" {
+		return fmt.Errorf("question is empty")
+	}
+
+	switch card.Type {
+	case CardBasic:
+		if strings.TrimSpace(card.Answer) == "" {
+			return fmt.Errorf("answer is empty")
+		}
+	case CardCloze:
+		if len(cloze.Indexes(card.Question)) == 0 {
+			return fmt.Errorf("cloze card has no {{c1::...}} deletions")
+		}
+	default:
+		return fmt.Errorf("unknown type %q (must be %q or %q)", card.Type, CardBasic, CardCloze)
+	}
+
+	return nil
+}
+
+// Verdict is a model's judgement of whether a flashcard's answer is supported
+// by the notes it was generated from
+type Verdict struct {
+	Supported bool   `json:"supported"`
+	Quote     string `json:"quote"` // Passage of the notes that supports the answer, word for word
+}
+
+// verdictList is the object a model replies with when checking flashcards
+type verdictList struct {
+	Verdicts []Verdict `json:"verdicts"`
+}
+
+// VerdictSchema asks for a verdict on each of a list of flashcards, in order
+var VerdictSchema = Schema{
+	Name:        "verdicts",
+	Description: "Whether the notes support each flashcard's answer",
+	Definition: json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "verdicts": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {
+          "supported": {"type": "boolean", "description": "true only if the notes state the answer"},
+          "quote": {"type": "string", "description": "The passage of the notes that states the answer, quoted word for word; empty if unsupported"}
+        },
+        "required": ["supported", "quote"],
+        "additionalProperties": false
+      }
+    }
+  },
+  "required": ["verdicts"],
+  "additionalProperties": false
+}`),
+}
+
+// ParseVerdicts reads a reply written to VerdictSchema, checking that it has
+// a verdict for each of count flashcards
+func ParseVerdicts(content string, count int) ([]Verdict, error) {
+	var list verdictList
+	if err := decodeReply(content, &list); err != nil {
+		return nil, err
+	}
+	if len(list.Verdicts) != count {
+		return nil, fmt.Errorf("response has %d verdicts for %d flashcards", len(list.Verdicts), count)
+	}
+	return list.Verdicts, nil
+}
@@ -0,0 +1,143 @@
+package generator
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"time"
+)
+
+// Price is what a model costs, in US dollars per million tokens
+type Price struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// Cost returns what usage costs at this price
+func (p Price) Cost(usage Usage) float64 {
+	return (float64(usage.InputTokens)*p.Input + float64(usage.OutputTokens)*p.Output) / 1e6
+}
+
+// Prices lists the published prices of common models. Models with a dated
+// version, such as gpt-4.1-nano-2025-04-14, are priced by the longest name
+// they start with.
+var Prices = map[string]Price{
+	"gpt-4.1":           {Input: 2.00, Output: 8.00},
+	"gpt-4.1-mini":      {Input: 0.40, Output: 1.60},
+	"gpt-4.1-nano":      {Input: 0.10, Output: 0.40},
+	"gpt-4o":            {Input: 2.50, Output: 10.00},
+	"gpt-4o-mini":       {Input: 0.15, Output: 0.60},
+	"claude-3-5-haiku":  {Input: 0.80, Output: 4.00},
+	"claude-3-haiku":    {Input: 0.25, Output: 1.25},
+	"claude-3-5-sonnet": {Input: 3.00, Output: 15.00},
+	"claude-3-7-sonnet": {Input: 3.00, Output: 15.00},
+	"claude-sonnet-4":   {Input: 3.00, Output: 15.00},
+	"claude-opus-4":     {Input: 15.00, Output: 75.00},
+
+	"text-embedding-3-small": {Input: 0.02},
+	"text-embedding-3-large": {Input: 0.13},
+}
+
+// PriceOf returns the price of a model, looking in extra before Prices
+func PriceOf(model string, extra map[string]Price) (Price, bool) {
+	if price, ok := extra[model]; ok {
+		return price, true
+	}
+
+	best, found := "", false
+	var price Price
+	for name, p := range Prices {
+		if strings.HasPrefix(model, name) && len(name) > len(best) {
+			best, price, found = name, p, true
+		}
+	}
+	return price, found
+}
+
+// UsageTotal adds up the requests made with one provider and model
+type UsageTotal struct {
+	Provider string
+	Model    string
+	Requests int // Requests sent to the API
+	Cached   int // Requests answered from the cache
+	Usage
+}
+
+// usageRecord is a line of the usage log
+type usageRecord struct {
+	Time         time.Time `json:"time"`
+	Provider     string    `json:"provider"`
+	Model        string    `json:"model"`
+	InputTokens  int       `json:"input_tokens"`
+	OutputTokens int       `json:"output_tokens"`
+}
+
+// meter counts the tokens a generator uses, adding them to a set's totals and
+// its usage log
+type meter struct {
+	Generator
+	set   *Set
+	total *UsageTotal
+}
+
+func (m *meter) Generate(ctx context.Context, req Request) (Response, error) {
+	resp, err := m.Generator.Generate(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+	m.set.count(m.total, resp.Usage)
+	return resp, nil
+}
+
+// embedMeter counts the tokens an embedder uses, like meter
+type embedMeter struct {
+	Embedder
+	set   *Set
+	total *UsageTotal
+}
+
+func (m *embedMeter) Embed(ctx context.Context, texts []string) ([][]float64, Usage, error) {
+	vectors, usage, err := m.Embedder.Embed(ctx, texts)
+	if err != nil {
+		return vectors, usage, err
+	}
+	m.set.count(m.total, usage)
+	return vectors, usage, nil
+}
+
+// count adds a request's usage to a total and the usage log
+func (s *Set) count(total *UsageTotal, usage Usage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	total.Requests++
+	total.InputTokens += usage.InputTokens
+	total.OutputTokens += usage.OutputTokens
+
+	if s.UsageLog != "" {
+		s.logUsage(usageRecord{
+			Time:         time.Now(),
+			Provider:     total.Provider,
+			Model:        total.Model,
+			InputTokens:  usage.InputTokens,
+			OutputTokens: usage.OutputTokens,
+		})
+	}
+}
+
+// logUsage appends a record to the usage log; like the cache, failing to
+// write it doesn't fail the request
+func (s *Set) logUsage(record usageRecord) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+
+	f, err := os.OpenFile(s.UsageLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.Write(append(data, '\n'))
+}
@@ -3,71 +3,245 @@ package processor
 import (
 	"context"
 	"fmt"
-	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/sashabaranov/go-openai"
+	"github.com/valdezdata/md-study/internal/cloze"
+	"github.com/valdezdata/md-study/internal/generator"
 	"github.com/valdezdata/md-study/internal/storage"
 )
 
-// GenerateFlashcards uses AI to create flashcards from notes
-func GenerateFlashcards(noteID string) ([]storage.Flashcard, error) {
-	note, err := storage.GetNote(noteID)
+// responseListMarker matches a number or bullet before a line of a reply, as in "1. Q: ..."
+var responseListMarker = regexp.MustCompile(`^(?:\d{1,3}[.)]|[-*+])\s+`)
+
+// systemPrompt tells the model what it is for
+const systemPrompt = "You are a helpful assistant that creates effective flashcards for learning."
+
+// GenerateFlashcards uses AI to create flashcards from notes. The note is sent
+// in chunks of whole sections where they fit, with their heading path as
+// context, and the flashcards record the section they came from.
+func GenerateFlashcards(ctx context.Context, gen generator.Generator, store storage.Store, noteID string, opts GenerateOptions) ([]storage.Flashcard, error) {
+	note, err := store.GetNote(noteID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get note: %w", err)
 	}
 
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		return nil, fmt.Errorf("OPENAI_API_KEY environment variable not set")
+	var existing []storage.Flashcard
+	if opts.Add > 0 {
+		cards, err := store.GetAllFlashcards()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get flashcards: %w", err)
+		}
+		for _, card := range cards {
+			if card.NoteID == note.ID {
+				existing = append(existing, card)
+			}
+		}
 	}
 
-	client := openai.NewClient(apiKey)
+	return generateNoteFlashcards(ctx, gen, note, existing, opts, func(format string, args ...any) {
+		fmt.Printf(format+"\n", args...)
+	})
+}
 
-	// Construct the prompt
-	prompt := fmt.Sprintf("Create 5 flashcards in question-answer format from the following notes. Format each as 'Q: [question]' on one line and 'A: [answer]' on another line.\n\nNotes:\n%s", note.RawContent)
+// generateNoteFlashcards creates the flashcards for a note, reporting
+// problems that were worked around with logf. Cards whose answer can't be
+// found in the note are marked unverified. When adding cards, the model is
+// told about the note's existing cards so it writes different ones.
+func generateNoteFlashcards(ctx context.Context, gen generator.Generator, note storage.Note, existing []storage.Flashcard, opts GenerateOptions, logf func(format string, args ...any)) ([]storage.Flashcard, error) {
+	requests, err := noteRequests(note, existing, opts)
+	if err != nil {
+		return nil, err
+	}
 
-	resp, err := client.CreateChatCompletion(
-		context.Background(),
-		openai.ChatCompletionRequest{
-			Model: "gpt-4.1-nano", // You can change to your preferred model
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    "system",
-					Content: "You are a helpful assistant that creates effective flashcards for learning.",
-				},
-				{
-					Role:    "user",
-					Content: prompt,
-				},
-			},
-			Temperature: 0.3, // Lower temperature for more consistent output
-		},
-	)
+	var flashcards []storage.Flashcard
+	for _, r := range requests {
+		cards, err := generateChunkFlashcards(ctx, gen, note, r.req, logf)
+		if err != nil {
+			return nil, err
+		}
+		groundCards(ctx, gen, note, r.chunk.Content, cards, opts.Verify, logf)
+
+		for j := range cards {
+			cards[j].Tags = uniqueTags(append(slices.Clone(note.Tags), cards[j].Tags...))
+			cards[j].Section = r.chunk.sectionFor(cards[j])
+			cards[j].Source = storage.SourceGenerated
+		}
+		flashcards = append(flashcards, cards...)
+	}
+
+	return dedupeCards(flashcards), nil
+}
+
+// noteChunks splits the sections of a note with content into the chunks sent to the model
+func noteChunks(note storage.Note) []chunk {
+	var sections []section
+	for _, s := range parseSections(note.RawContent) {
+		if s.HasContent() {
+			sections = append(sections, s)
+		}
+	}
+	return chunkSections(note.RawContent, sections, maxChunkTokens)
+}
+
+// chunkRequest is the request for one chunk of a note
+type chunkRequest struct {
+	chunk chunk
+	req   generator.Request
+}
 
+// noteRequests splits a note into chunks and writes the request for each with
+// the prompt template the note's tags pick. When adding cards, the cards asked
+// for are spread over the chunks, and the prompts list the existing cards.
+func noteRequests(note storage.Note, existing []storage.Flashcard, opts GenerateOptions) ([]chunkRequest, error) {
+	tmpl := opts.Prompts.forTags(note.Tags)
+	tokensPerCard := opts.TokensPerCard
+	if tokensPerCard <= 0 {
+		tokensPerCard = DefaultTokensPerCard
+	}
+
+	chunks := noteChunks(note)
+	counts := make([]int, len(chunks))
+	for i, c := range chunks {
+		counts[i] = c.CardCount(tokensPerCard)
+	}
+	var asked string
+	if opts.Add > 0 {
+		counts = spreadCards(chunks, opts.Add)
+		asked = existingQuestions(existing)
+	}
+
+	var requests []chunkRequest
+	for i, c := range chunks {
+		count := counts[i]
+		if count == 0 {
+			continue
+		}
+		prompt, err := renderPrompt(tmpl, promptData{
+			Title:   note.Title,
+			Tags:    note.Tags,
+			Section: strings.Join(c.Path(), " > "),
+			Count:   count,
+			Notes:   c.Content,
+		}, asked, rejectedExamples(opts.Rejected, note.Deck))
+		if err != nil {
+			return nil, err
+		}
+
+		requests = append(requests, chunkRequest{chunk: c, req: generator.Request{
+			System:   systemPrompt,
+			Prompt:   prompt,
+			Schema:   &generator.CardSchema,
+			Source:   c.Content,
+			Section:  c.Path(),
+			Count:    count,
+			NoteHash: note.ContentHash,
+			Validate: validCardReply,
+		}})
+	}
+
+	return requests, nil
+}
+
+// generateChunkFlashcards sends the request for one chunk of a note, asking
+// for flashcards as JSON. A reply that doesn't fit the schema is sent back
+// with what is wrong for one more try; if that fails too, cards written in the
+// older Q:/A: line format are still accepted.
+func generateChunkFlashcards(ctx context.Context, gen generator.Generator, note storage.Note, req generator.Request, logf func(format string, args ...any)) ([]storage.Flashcard, error) {
+	prompt := req.Prompt
+	resp, err := gen.Generate(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("OpenAI API error: %w", err)
+		return nil, err
+	}
+
+	cards, parseErr := generator.ParseCards(resp.Content)
+	if parseErr != nil {
+		logf("%s: malformed response, asking again: %v", note.Filename, parseErr)
+
+		req.Prompt = fmt.Sprintf("%s\n\nYour previous reply was:\n%s\n\nIt is invalid: %s\nReply again with only the corrected JSON object.",
+			prompt, resp.Content, parseErr)
+		resp, err = gen.Generate(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		cards, parseErr = generator.ParseCards(resp.Content)
+	}
+
+	if parseErr != nil {
+		flashcards, _ := parseFlashcardsFromResponse(resp.Content, note.ID)
+		if len(flashcards) == 0 {
+			return nil, fmt.Errorf("model reply is not valid flashcard JSON: %w", parseErr)
+		}
+		return flashcards, nil
 	}
 
-	// Parse the AI response into flashcards
-	return parseFlashcardsFromResponse(resp.Choices[0].Message.Content, noteID)
+	return cardsFromSchema(cards, note.ID), nil
 }
 
-// parseFlashcardsFromResponse extracts Q&A pairs from the AI response
+// validCardReply checks that a reply holds flashcards, as JSON or in the
+// Q:/A: line format, so only replies that give cards are cached
+func validCardReply(content string) error {
+	_, err := generator.ParseCards(content)
+	if err == nil {
+		return nil
+	}
+	if cards, _ := parseFlashcardsFromResponse(content, ""); len(cards) > 0 {
+		return nil
+	}
+	return err
+}
+
+// cardsFromSchema turns the cards a model replied with into flashcards; a
+// cloze card becomes one flashcard for each of its deletions
+func cardsFromSchema(cards []generator.Card, noteID string) []storage.Flashcard {
+	var flashcards []storage.Flashcard
+	for _, card := range cards {
+		var made []storage.Flashcard
+		if card.Type == generator.CardCloze {
+			made = clozeCards(noteID, strings.TrimSpace(card.Question))
+		} else {
+			made = []storage.Flashcard{{
+				NoteID:     noteID,
+				Type:       storage.TypeBasic,
+				Question:   strings.TrimSpace(card.Question),
+				Answer:     strings.TrimSpace(card.Answer),
+				NextReview: time.Now(),
+			}}
+		}
+
+		for i := range made {
+			made[i].Tags = card.Tags
+			made[i].SourceQuote = strings.TrimSpace(card.SourceQuote)
+		}
+		flashcards = append(flashcards, made...)
+	}
+	return flashcards
+}
+
+// parseFlashcardsFromResponse extracts Q&A pairs and cloze cards from a reply
+// written as lines, for models that don't follow the JSON schema
 func parseFlashcardsFromResponse(response, noteID string) ([]storage.Flashcard, error) {
 	lines := strings.Split(response, "\n")
 	var flashcards []storage.Flashcard
 
+	// Models often number their cards or write them as a list
+	for i, line := range lines {
+		lines[i] = responseListMarker.ReplaceAllString(strings.TrimSpace(line), "")
+	}
+
 	var currentQuestion, currentAnswer string
 	for i, line := range lines {
-		line = strings.TrimSpace(line)
 
 		if strings.HasPrefix(line, "Q:") {
 			// If we already have a question and answer, save the flashcard
 			if currentQuestion != "" && currentAnswer != "" {
 				flashcards = append(flashcards, storage.Flashcard{
 					NoteID:     noteID,
+					Type:       storage.TypeBasic,
 					Question:   currentQuestion,
 					Answer:     currentAnswer,
 					Difficulty: 0, // Initial difficulty
@@ -86,6 +260,7 @@ func parseFlashcardsFromResponse(response, noteID string) ([]storage.Flashcard,
 			if (i == len(lines)-1 || strings.HasPrefix(strings.TrimSpace(lines[i+1]), "Q:")) && currentQuestion != "" {
 				flashcards = append(flashcards, storage.Flashcard{
 					NoteID:     noteID,
+					Type:       storage.TypeBasic,
 					Question:   currentQuestion,
 					Answer:     currentAnswer,
 					Difficulty: 0, // Initial difficulty
@@ -93,16 +268,99 @@ func parseFlashcardsFromResponse(response, noteID string) ([]storage.Flashcard,
 				})
 				currentQuestion, currentAnswer = "", ""
 			}
+		} else if strings.HasPrefix(line, "C:") {
+			// A pending question and answer ends here
+			if currentQuestion != "" && currentAnswer != "" {
+				flashcards = append(flashcards, storage.Flashcard{
+					NoteID:     noteID,
+					Type:       storage.TypeBasic,
+					Question:   currentQuestion,
+					Answer:     currentAnswer,
+					NextReview: time.Now(),
+				})
+			}
+			currentQuestion, currentAnswer = "", ""
+
+			text := strings.TrimSpace(strings.TrimPrefix(line, "C:"))
+			flashcards = append(flashcards, clozeCards(noteID, text)...)
 		}
 	}
 
 	return flashcards, nil
 }
 
-// GenerateFlashcardsForAllNotes processes all imported notes and creates flashcards
-func GenerateFlashcardsForAllNotes() error {
+// GenerateOptions controls which notes GenerateFlashcardsForAllNotes processes
+type GenerateOptions struct {
+	// Changed regenerates cards for notes edited since their cards were generated,
+	// instead of generating cards for notes that have none
+	Changed bool
+
+	// Generators picks the model provider for each note's deck
+	Generators *generator.Set
+
+	// Workers is how many notes are generated for at the same time
+	Workers int
+
+	// Prompts picks the prompt template for each note; nil for the built-in prompt
+	Prompts *Prompts
+
+	// TokensPerCard sets how many flashcards are asked for: one for every
+	// TokensPerCard tokens of note text, or DefaultTokensPerCard if 0
+	TokensPerCard int
+
+	// DryRun estimates the tokens and cost of generating instead, without calling any model
+	DryRun bool
+
+	// Verify asks the model to check the cards whose answer can't be matched
+	// to the note, before marking them unverified
+	Verify bool
+
+	// Accept puts new cards straight into study, instead of holding them as
+	// pending until they are reviewed
+	Accept bool
+
+	// Rejected lists flashcards rejected in review; prompts ask the model to
+	// avoid cards like the ones rejected in the note's deck
+	Rejected []RejectedCard
+
+	// Note, if set, generates for just this note, given by ID or file path,
+	// instead of every note without flashcards
+	Note string
+
+	// Replace regenerates the cards of the note, whether or not it changed;
+	// cards whose question is still asked keep their ID and history
+	Replace bool
+
+	// Add generates this many more cards for the note, different from the
+	// ones it has, leaving those alone
+	Add int
+}
+
+// noteJob is a note waiting for flashcards, with the generator for its deck
+// and the generated cards it has
+type noteJob struct {
+	note     storage.Note
+	gen      generator.Generator
+	existing []storage.Flashcard
+}
+
+// noteResult is the outcome of generating flashcards for one note
+type noteResult struct {
+	note  storage.Note
+	cards []storage.Flashcard
+	err   error
+}
+
+// GenerateFlashcardsForAllNotes processes all imported notes and creates
+// flashcards. Notes are generated for by a pool of workers, but their cards are
+// saved one note at a time as they finish, so a note that fails or a run
+// cancelled through ctx leaves every other note either done or untouched.
+// Failed notes are reported without stopping the rest; running again picks up
+// whatever is left. With opts.Note set, only that note is generated for, and
+// its cards can be replaced or added to.
+func GenerateFlashcardsForAllNotes(ctx context.Context, store storage.Store, opts GenerateOptions) error {
 	// Get all notes
-	notes, err := storage.GetAllNotes()
+	notes, err := store.GetAllNotes()
 	if err != nil {
 		return fmt.Errorf("failed to get notes: %w", err)
 	}
@@ -111,60 +369,315 @@ func GenerateFlashcardsForAllNotes() error {
 		return fmt.Errorf("no notes found - please import some markdown files first")
 	}
 
-	fmt.Printf("Found %d notes to process\n", len(notes))
-
 	// First, get all existing flashcards
-	existingCards, err := storage.GetAllFlashcards()
+	existingCards, err := store.GetAllFlashcards()
 	if err != nil {
 		return fmt.Errorf("failed to get existing flashcards: %w", err)
 	}
 
-	// Create a map to track which notes already have flashcards
-	notesWithCards := make(map[string]bool)
+	// Group the existing generated flashcards by the note they came from;
+	// flashcards written in the notes are never changed by generation
+	cardsByNote := make(map[string][]storage.Flashcard)
 	for _, card := range existingCards {
-		notesWithCards[card.NoteID] = true
+		if card.Source == storage.SourceInline {
+			continue
+		}
+		cardsByNote[card.NoteID] = append(cardsByNote[card.NoteID], card)
 	}
 
-	// Track how many notes were processed
-	processedCount := 0
+	var pending []storage.Note
+	if opts.Note != "" {
+		note, err := findNote(notes, opts.Note)
+		if err != nil {
+			return err
+		}
+		switch {
+		case note.Orphaned:
+			return fmt.Errorf("%s was deleted or opted out of study", note.Filename)
+		case len(cardsByNote[note.ID]) > 0 && !opts.Replace && opts.Add == 0:
+			return fmt.Errorf("%s already has flashcards; use --replace to regenerate them or --add N to add more", note.Filename)
+		}
+		pending = append(pending, note)
+	} else {
+		fmt.Printf("Found %d notes to process\n", len(notes))
+		for i, note := range notes {
+			// Notes whose files were deleted are waiting to be archived or deleted
+			if note.Orphaned {
+				continue
+			}
 
-	for i, note := range notes {
-		// Skip notes that already have flashcards
-		if notesWithCards[note.ID] {
-			fmt.Printf("[%d/%d] Skipping %s (already has flashcards)\n", i+1, len(notes), note.Filename)
-			continue
+			hasCards := len(cardsByNote[note.ID]) > 0
+
+			if opts.Changed {
+				if !hasCards || !noteChanged(note) {
+					continue
+				}
+			} else if hasCards {
+				// Skip notes that already have flashcards
+				fmt.Printf("[%d/%d] Skipping %s (already has flashcards)\n", i+1, len(notes), note.Filename)
+				continue
+			}
+
+			pending = append(pending, note)
+		}
+	}
+
+	if len(pending) == 0 {
+		if opts.Changed {
+			fmt.Println("No changed notes to process. All flashcards are up to date.")
+		} else {
+			fmt.Println("No new notes to process. All notes already have flashcards.")
 		}
+		return nil
+	}
 
-		fmt.Printf("[%d/%d] Generating flashcards for %s...\n", i+1, len(notes), note.Filename)
-		processedCount++
+	if opts.DryRun {
+		return estimateGeneration(pending, cardsByNote, opts)
+	}
+
+	var jobs []noteJob
+	for _, note := range pending {
+		// Settings problems such as a missing API key affect every note, so they stop the run here
+		gen, err := opts.Generators.For(note.Deck)
+		if err != nil {
+			return fmt.Errorf("failed to set up generator for %s: %w", note.Filename, err)
+		}
+		jobs = append(jobs, noteJob{note: note, gen: gen, existing: cardsByNote[note.ID]})
+	}
+
+	workers := max(1, min(opts.Workers, len(jobs)))
+	fmt.Printf("Generating flashcards for %d notes, %d at a time...\n", len(jobs), workers)
+
+	bar := newProgress(len(jobs))
+	saved := 0
+	var failed []string
+	for result := range generateAll(ctx, jobs, workers, opts, bar) {
+		// Notes cut off by cancellation are neither done nor failed
+		if result.err != nil && ctx.Err() != nil {
+			continue
+		}
 
-		// Generate flashcards for this note
-		flashcards, err := GenerateFlashcards(note.ID)
+		note := result.note
+		err := result.err
+		var message string
+		if err == nil {
+			if opts.Add > 0 {
+				message, err = saveAdded(store, cardsByNote[note.ID], result.cards, opts.Add, opts.Accept)
+			} else {
+				message, err = saveGenerated(store, note, cardsByNote[note.ID], result.cards, opts.Accept)
+			}
+		}
+		done := bar.Step()
 		if err != nil {
-			return fmt.Errorf("failed to generate flashcards for %s: %w", note.Filename, err)
+			bar.Logf("[%d/%d] %s: %v", done, len(jobs), note.Filename, err)
+			failed = append(failed, note.Filename)
+			continue
+		}
+		saved++
+		bar.Logf("[%d/%d] %s: %s", done, len(jobs), note.Filename, message)
+	}
+	bar.Finish()
+	printUsage(opts.Generators)
+	if waiting, err := GetNewCards(store); err == nil && len(waiting) > 0 {
+		fmt.Printf("%d new flashcards are waiting for review; run 'md-study review-new' to study them\n", len(waiting))
+	}
+
+	if ctx.Err() != nil {
+		fmt.Printf("Interrupted: saved flashcards for %d of %d notes; run the same command again to continue\n", saved, len(jobs))
+		return fmt.Errorf("generation interrupted")
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to generate flashcards for %d of %d notes: %s", len(failed), len(jobs), strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// generateAll sends jobs to a pool of workers, returning their results as
+// they finish. Once ctx is cancelled no more jobs are started; the channel is
+// closed when every started job is done.
+func generateAll(ctx context.Context, jobs []noteJob, workers int, opts GenerateOptions, bar *progress) <-chan noteResult {
+	queue := make(chan noteJob)
+	go func() {
+		defer close(queue)
+		for _, job := range jobs {
+			select {
+			case queue <- job:
+			case <-ctx.Done():
+				return
+			}
 		}
+	}()
+
+	results := make(chan noteResult)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				cards, err := generateNoteFlashcards(ctx, job.gen, job.note, job.existing, opts, bar.Logf)
+				results <- noteResult{note: job.note, cards: cards, err: err}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
 
+	return results
+}
+
+// saveGenerated stores the flashcards generated for a note, merging them with
+// the ones it had, marks the note's cards as up to date and describes what
+// changed. New cards are pending unless accept is set.
+func saveGenerated(store storage.Store, note storage.Note, existing, flashcards []storage.Flashcard, accept bool) (string, error) {
+	var message string
+	if len(existing) > 0 {
+		merge := mergeCards(existing, flashcards)
+		if !accept {
+			holdForReview(merge.Add)
+		}
+		if err := applyMerge(store, merge); err != nil {
+			return "", err
+		}
+		message = fmt.Sprintf("kept %d, added %d, removed %d flashcards", len(merge.Keep), len(merge.Add), len(merge.Remove))
+	} else {
+		if !accept {
+			holdForReview(flashcards)
+		}
 		// Save each flashcard
 		for _, card := range flashcards {
-			if err := storage.SaveFlashcard(card); err != nil {
-				return fmt.Errorf("failed to save flashcard: %w", err)
+			if err := store.SaveFlashcard(card); err != nil {
+				return "", fmt.Errorf("failed to save flashcard: %w", err)
 			}
 		}
+		message = fmt.Sprintf("created %d flashcards", len(flashcards))
+	}
+	if n := countStatus(flashcards, storage.StatusUnverified); n > 0 {
+		message += fmt.Sprintf(" (%d unverified)", n)
+	}
+
+	// Remember which version of the note these cards were generated from
+	note.CardsHash = note.ContentHash
+	if err := store.SaveNote(note); err != nil {
+		return "", fmt.Errorf("failed to update note: %w", err)
+	}
+
+	return message, nil
+}
+
+// saveAdded stores up to add of the flashcards generated for a note as new
+// cards, leaving out any that ask the same as one of its existing cards
+func saveAdded(store storage.Store, existing, flashcards []storage.Flashcard, add int, accept bool) (string, error) {
+	var added []storage.Flashcard
+	for _, card := range flashcards {
+		if len(added) == add {
+			break
+		}
+		if !slices.ContainsFunc(existing, func(old storage.Flashcard) bool { return sameCard(old, card) }) {
+			added = append(added, card)
+		}
+	}
+
+	if !accept {
+		holdForReview(added)
+	}
+	for _, card := range added {
+		if err := store.SaveFlashcard(card); err != nil {
+			return "", fmt.Errorf("failed to save flashcard: %w", err)
+		}
+	}
+
+	message := fmt.Sprintf("added %d flashcards", len(added))
+	if skipped := len(flashcards) - len(added); skipped > 0 && len(added) < add {
+		message += fmt.Sprintf(", left out %d like existing ones", skipped)
+	}
+	if n := countStatus(added, storage.StatusUnverified); n > 0 {
+		message += fmt.Sprintf(" (%d unverified)", n)
+	}
+	return message, nil
+}
+
+// findNote finds a note by its ID, the path of its file (absolute or relative
+// to the working directory) or, if no other note has it, its file name
+func findNote(notes []storage.Note, ref string) (storage.Note, error) {
+	paths := []string{ref}
+	if abs, err := filepath.Abs(ref); err == nil {
+		paths = append(paths, abs)
+		if real, err := filepath.EvalSymlinks(abs); err == nil {
+			paths = append(paths, real)
+		}
+	}
+
+	var byName []storage.Note
+	for _, note := range notes {
+		if note.ID == ref || slices.Contains(paths, note.FilePath) {
+			return note, nil
+		}
+		if note.Filename == ref {
+			byName = append(byName, note)
+		}
+	}
+
+	switch len(byName) {
+	case 0:
+		return storage.Note{}, fmt.Errorf("no imported note %s", ref)
+	case 1:
+		return byName[0], nil
+	default:
+		return storage.Note{}, fmt.Errorf("%d imported notes are named %s; give the note's path instead", len(byName), ref)
+	}
+}
+
+// holdForReview makes new cards pending, so they aren't studied until they
+// are reviewed; unverified cards stay unverified
+func holdForReview(cards []storage.Flashcard) {
+	for i := range cards {
+		if cards[i].Status == "" || cards[i].Status == storage.StatusActive {
+			cards[i].Status = storage.StatusPending
+		}
+	}
+}
+
+// countStatus counts the cards with a status
+func countStatus(cards []storage.Flashcard, status string) int {
+	n := 0
+	for _, card := range cards {
+		if card.Status == status {
+			n++
+		}
+	}
+	return n
+}
 
-		fmt.Printf("  Created %d flashcards\n", len(flashcards))
+// noteChanged reports whether a note was edited after its flashcards were generated
+func noteChanged(note storage.Note) bool {
+	return note.ContentHash != "" && note.CardsHash != note.ContentHash
+}
+
+// applyMerge saves the result of regenerating a note's flashcards
+func applyMerge(store storage.Store, merge cardMerge) error {
+	for _, card := range append(merge.Keep, merge.Add...) {
+		if err := store.SaveFlashcard(card); err != nil {
+			return fmt.Errorf("failed to save flashcard: %w", err)
+		}
 	}
 
-	if processedCount == 0 {
-		fmt.Println("No new notes to process. All notes already have flashcards.")
+	for _, card := range merge.Remove {
+		if err := store.DeleteFlashcard(card.ID); err != nil {
+			return fmt.Errorf("failed to delete flashcard: %w", err)
+		}
 	}
 
 	return nil
 }
 
 // ListAllFlashcards displays all flashcards in the system
-func ListAllFlashcards() error {
+func ListAllFlashcards(store storage.Store) error {
 	// Get all flashcards
-	cards, err := storage.GetAllFlashcards()
+	cards, err := store.GetAllFlashcards()
 	if err != nil {
 		return fmt.Errorf("failed to get flashcards: %w", err)
 	}
@@ -178,9 +691,22 @@ func ListAllFlashcards() error {
 
 	for i, card := range cards {
 		fmt.Printf("Flashcard #%d:\n", i+1)
-		fmt.Printf("Question: %s\n", card.Question)
+		if card.Type == storage.TypeCloze {
+			fmt.Printf("Cloze: %s\n", cloze.Blank(card.Question, card.ClozeIndex))
+		} else {
+			fmt.Printf("Question: %s\n", card.Question)
+		}
 		fmt.Printf("Answer: %s\n", card.Answer)
+		if len(card.Section) > 0 {
+			fmt.Printf("Section: %s\n", strings.Join(card.Section, " > "))
+		}
+		if len(card.Tags) > 0 {
+			fmt.Printf("Tags: %s\n", strings.Join(card.Tags, ", "))
+		}
 		fmt.Printf("Next review: %s\n", card.NextReview.Format("2006-01-02 15:04:05"))
+		if card.Status != storage.StatusActive {
+			fmt.Printf("Status: %s\n", card.Status)
+		}
 		fmt.Println("---------------------------------------")
 	}
 
@@ -188,6 +714,6 @@ func ListAllFlashcards() error {
 }
 
 // DeleteAllFlashcards removes all flashcards
-func DeleteAllFlashcards() error {
-	return storage.DeleteAllFlashcards()
+func DeleteAllFlashcards(store storage.Store) error {
+	return store.DeleteAllFlashcards()
 }
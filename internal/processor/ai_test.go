@@ -0,0 +1,58 @@
+package processor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/valdezdata/md-study/internal/generator"
+	"github.com/valdezdata/md-study/internal/storage"
+)
+
+// flakyGenerator replies with text that isn't flashcards to its first bad
+// requests, then with one valid card
+type flakyGenerator struct {
+	bad   int
+	calls int
+}
+
+func (g *flakyGenerator) Generate(context.Context, generator.Request) (generator.Response, error) {
+	g.calls++
+	if g.calls <= g.bad {
+		return generator.Response{Content: "Sure! Here are your flashcards."}, nil
+	}
+	return generator.Response{Content: `{"cards": [{"type": "basic", "question": "What port does HTTPS use?", "answer": "443", "tags": [], "source_quote": "HTTPS uses port 443."}]}`}, nil
+}
+
+func TestGenerateDoesNotCacheBadReplies(t *testing.T) {
+	note := storage.Note{ID: "n1", Filename: "http.md", ContentHash: "h1"}
+	req := generator.Request{System: systemPrompt, Prompt: "Write one card.", Schema: &generator.CardSchema,
+		NoteHash: note.ContentHash, Validate: validCardReply}
+	fake := &flakyGenerator{bad: 2}
+	cache := &generator.Cache{Generator: fake, Dir: t.TempDir(), Settings: generator.Settings{Provider: "flaky"}}
+	logf := func(string, ...any) {}
+
+	// Both the reply and the corrected reply are bad, so the note fails
+	if _, err := generateChunkFlashcards(context.Background(), cache, note, req, logf); err == nil {
+		t.Fatal("generateChunkFlashcards accepted a reply with no cards")
+	}
+
+	// Asking again reaches the model, which now replies with a card
+	cards, err := generateChunkFlashcards(context.Background(), cache, note, req, logf)
+	if err != nil {
+		t.Fatalf("generateChunkFlashcards: %v", err)
+	}
+	if len(cards) != 1 || cards[0].Answer != "443" {
+		t.Errorf("cards = %+v, want the card for port 443", cards)
+	}
+	if fake.calls != 3 {
+		t.Errorf("model called %d times, want 3", fake.calls)
+	}
+
+	// The good reply is cached
+	if _, err := generateChunkFlashcards(context.Background(), cache, note, req, logf); err != nil {
+		t.Fatalf("generateChunkFlashcards: %v", err)
+	}
+	if fake.calls != 3 {
+		t.Errorf("model called %d times after a cached reply, want 3", fake.calls)
+	}
+}
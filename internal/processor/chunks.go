@@ -0,0 +1,229 @@
+package processor
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/valdezdata/md-study/internal/storage"
+)
+
+// Notes are sent to the model in chunks small enough for the context window of
+// small local models, leaving room for the prompt and the reply, and the
+// number of cards asked for grows with the length of each chunk
+const (
+	maxChunkTokens       = 1500 // Most note text sent in one request
+	DefaultTokensPerCard = 60   // Note text for each flashcard asked for
+)
+
+// chunk is the part of a note sent to the model in one request: several
+// whole sections, or part of a section too long to send at once
+type chunk struct {
+	Sections []section // The sections the text comes from
+	Content  string
+	Tokens   int
+}
+
+// Path returns the heading path shared by all the chunk's sections
+func (c chunk) Path() []string {
+	if len(c.Sections) == 0 {
+		return nil
+	}
+	path := c.Sections[0].Path
+	for _, s := range c.Sections[1:] {
+		n := 0
+		for n < len(path) && n < len(s.Path) && path[n] == s.Path[n] {
+			n++
+		}
+		path = path[:n]
+	}
+	return path
+}
+
+// CardCount is the number of flashcards to ask for, one for every
+// tokensPerCard of text: at least one, and no more than a full chunk's worth
+// for a block too long to split, which is still one if tokensPerCard is more
+// than a chunk holds
+func (c chunk) CardCount(tokensPerCard int) int {
+	return min(max(1, (c.Tokens+tokensPerCard-1)/tokensPerCard), max(1, maxChunkTokens/tokensPerCard))
+}
+
+// sectionFor returns the heading path of the section a card came from: the one
+// holding its source quote, or the path the chunk's sections share if the
+// quote can't be found
+func (c chunk) sectionFor(card storage.Flashcard) []string {
+	if len(c.Sections) == 1 {
+		return c.Sections[0].Path
+	}
+
+	if quote := normalizeSpace(card.SourceQuote); quote != "" {
+		for _, s := range c.Sections {
+			if strings.Contains(normalizeSpace(s.Content), quote) {
+				return s.Path
+			}
+		}
+	}
+	return c.Path()
+}
+
+// estimateTokens roughly counts the tokens of text, at about four characters a
+// token as in English text; it only needs to be close enough to size chunks
+func estimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// chunkSections groups a note's sections into chunks of at most budget tokens,
+// keeping sections whole where they fit. A longer section is split between its
+// blocks, with its heading repeated in every part; a single block that is
+// still too long, such as a big code block, is sent on its own.
+func chunkSections(rawContent string, sections []section, budget int) []chunk {
+	lines := strings.Split(rawContent, "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSuffix(lines[i], "\r")
+	}
+
+	var chunks []chunk
+	var current chunk
+	flush := func() {
+		if len(current.Sections) > 0 {
+			chunks = append(chunks, current)
+		}
+		current = chunk{}
+	}
+
+	for _, s := range sections {
+		tokens := estimateTokens(s.Content)
+		if tokens > budget {
+			flush()
+			chunks = append(chunks, splitSection(lines, s, budget)...)
+			continue
+		}
+
+		if current.Tokens+tokens > budget {
+			flush()
+		}
+		if current.Content != "" {
+			current.Content += "\n\n"
+		}
+		current.Sections = append(current.Sections, s)
+		current.Content += s.Content
+		current.Tokens += tokens
+	}
+	flush()
+
+	return chunks
+}
+
+// splitSection splits a section into chunks of at most budget tokens between its blocks
+func splitSection(lines []string, s section, budget int) []chunk {
+	// The heading is whatever comes before the first block: one line, or two for a setext heading
+	heading := strings.TrimSpace(strings.Join(lines[s.StartLine-1:s.Blocks[0].StartLine-1], "\n"))
+
+	var chunks []chunk
+	first := 0
+	for first < len(s.Blocks) {
+		last := first
+		text := blockText(lines, heading, s.Blocks[first:last+1])
+		for last+1 < len(s.Blocks) {
+			next := blockText(lines, heading, s.Blocks[first:last+2])
+			if estimateTokens(next) > budget {
+				break
+			}
+			last, text = last+1, next
+		}
+
+		chunks = append(chunks, chunk{Sections: []section{s}, Content: text, Tokens: estimateTokens(text)})
+		first = last + 1
+	}
+	return chunks
+}
+
+// blockText returns the text of a run of blocks under their section's heading
+func blockText(lines []string, heading string, blocks []block) string {
+	text := strings.Join(lines[blocks[0].StartLine-1:blocks[len(blocks)-1].EndLine], "\n")
+	if heading == "" {
+		return text
+	}
+	return heading + "\n\n" + text
+}
+
+// normalizeSpace lowercases text and collapses its whitespace, for finding quotes
+func normalizeSpace(text string) string {
+	return strings.ToLower(strings.Join(strings.Fields(text), " "))
+}
+
+// oneLine collapses the whitespace of text, line breaks included, to single spaces
+func oneLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// dedupeCards drops cards that ask the same as an earlier card, as happens
+// when chunks of a note cover the same fact
+func dedupeCards(cards []storage.Flashcard) []storage.Flashcard {
+	var unique []storage.Flashcard
+	for _, card := range cards {
+		duplicate := slices.ContainsFunc(unique, func(kept storage.Flashcard) bool {
+			return sameCard(kept, card)
+		})
+		if !duplicate {
+			unique = append(unique, card)
+		}
+	}
+	return unique
+}
+
+// sameCard reports whether two cards of a note ask the same question
+func sameCard(a, b storage.Flashcard) bool {
+	return a.Type == b.Type && a.ClozeIndex == b.ClozeIndex &&
+		similarity(a.Question, b.Question) >= sameQuestionThreshold
+}
+
+// spreadCards divides the cards to add to a note among its chunks by their
+// length; short chunks may get none
+func spreadCards(chunks []chunk, cards int) []int {
+	counts := make([]int, len(chunks))
+	total := 0
+	for _, c := range chunks {
+		total += max(c.Tokens, 1)
+	}
+	if total == 0 {
+		return counts
+	}
+
+	// Largest remainders first, so the counts add up to exactly cards
+	type share struct {
+		index     int
+		remainder int
+	}
+	shares := make([]share, len(chunks))
+	given := 0
+	for i, c := range chunks {
+		exact := cards * max(c.Tokens, 1)
+		counts[i] = exact / total
+		given += counts[i]
+		shares[i] = share{i, exact % total}
+	}
+	sort.SliceStable(shares, func(i, j int) bool { return shares[i].remainder > shares[j].remainder })
+	for k := 0; given < cards; k++ {
+		counts[shares[k%len(shares)].index]++
+		given++
+	}
+	return counts
+}
+
+// existingQuestions tells the model what a note's cards already ask, so the
+// cards it adds ask about something else
+func existingQuestions(cards []storage.Flashcard) string {
+	var questions []string
+	for _, card := range cards {
+		question := "- " + oneLine(cardText(card))
+		if !slices.Contains(questions, question) {
+			questions = append(questions, question)
+		}
+	}
+	if len(questions) == 0 {
+		return ""
+	}
+	return "The notes already have these flashcards. Write new ones that ask about something else:\n" + strings.Join(questions, "\n")
+}
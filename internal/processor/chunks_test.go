@@ -0,0 +1,23 @@
+package processor
+
+import "testing"
+
+func TestChunkCardCount(t *testing.T) {
+	tests := []struct {
+		tokens, tokensPerCard, want int
+	}{
+		{10, 60, 1},
+		{60, 60, 1},
+		{61, 60, 2},
+		{600, 60, 10},
+		{6000, 60, maxChunkTokens / 60}, // A block too long to split
+		{100, 2000, 1},                  // More tokens per card than a chunk holds
+		{6000, 2000, 1},
+	}
+
+	for _, tt := range tests {
+		if got := (chunk{Tokens: tt.tokens}).CardCount(tt.tokensPerCard); got != tt.want {
+			t.Errorf("CardCount(%d) of %d tokens = %d, want %d", tt.tokensPerCard, tt.tokens, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/valdezdata/md-study/internal/cloze"
+	"github.com/valdezdata/md-study/internal/generator"
+	"github.com/valdezdata/md-study/internal/storage"
+)
+
+// Similarities from which two flashcards are taken to ask the same thing
+const (
+	DefaultDuplicateThreshold = 0.6  // Of the words of their questions
+	DefaultEmbeddingThreshold = 0.92 // Of the embeddings of their questions
+)
+
+// DedupeOptions controls how duplicate flashcards are found
+type DedupeOptions struct {
+	Threshold float64 // Word similarity from which questions are duplicates
+
+	// Embeddings, if set, also compares the meaning of questions using the
+	// embeddings of each deck's provider, so differently worded questions
+	// with an embedding similarity of at least EmbeddingThreshold are found
+	Embeddings         *generator.Set
+	EmbeddingThreshold float64
+}
+
+// DuplicateCard is a flashcard in a cluster of duplicates, with its note and
+// the size of its review history
+type DuplicateCard struct {
+	storage.Flashcard
+	Note    storage.Note
+	Reviews int
+}
+
+// DuplicateCluster is a group of flashcards in one deck that ask the same
+// thing. The card with the best review history comes first.
+type DuplicateCluster struct {
+	Deck  string
+	Cards []DuplicateCard
+}
+
+// FindDuplicates compares the questions of the active flashcards in each
+// deck, and returns the clusters of cards that ask the same thing. Cards of
+// different types, and cloze cards hiding different deletions, are never
+// duplicates.
+func FindDuplicates(ctx context.Context, store storage.Store, opts DedupeOptions) ([]DuplicateCluster, error) {
+	notes, err := store.GetAllNotes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notes: %w", err)
+	}
+	cards, err := store.GetAllFlashcards()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get flashcards: %w", err)
+	}
+	reviews, err := store.GetAllReviews()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reviews: %w", err)
+	}
+
+	notesByID := make(map[string]storage.Note)
+	for _, note := range notes {
+		notesByID[note.ID] = note
+	}
+	reviewCounts := make(map[string]int)
+	for _, review := range reviews {
+		reviewCounts[review.CardID]++
+	}
+
+	decks := make(map[string][]DuplicateCard)
+	for _, card := range cards {
+		if card.Status != storage.StatusActive {
+			continue
+		}
+		note := notesByID[card.NoteID]
+		decks[note.Deck] = append(decks[note.Deck], DuplicateCard{Flashcard: card, Note: note, Reviews: reviewCounts[card.ID]})
+	}
+
+	deckNames := make([]string, 0, len(decks))
+	for deck := range decks {
+		deckNames = append(deckNames, deck)
+	}
+	sort.Strings(deckNames)
+
+	var clusters []DuplicateCluster
+	for _, deck := range deckNames {
+		found, err := findDeckDuplicates(ctx, deck, decks[deck], opts)
+		if err != nil {
+			return nil, err
+		}
+		clusters = append(clusters, found...)
+	}
+	return clusters, nil
+}
+
+// findDeckDuplicates clusters the duplicate flashcards of one deck: any two
+// cards similar enough end up in the same cluster
+func findDeckDuplicates(ctx context.Context, deck string, cards []DuplicateCard, opts DedupeOptions) ([]DuplicateCluster, error) {
+	texts := make([]string, len(cards))
+	words := make([]map[string]bool, len(cards))
+	for i, card := range cards {
+		texts[i] = cardText(card.Flashcard)
+		words[i] = wordSet(texts[i])
+	}
+
+	var vectors [][]float64
+	if opts.Embeddings != nil && len(cards) > 1 {
+		embedder, err := opts.Embeddings.Embedder(deck)
+		if err != nil {
+			return nil, err
+		}
+		vectors, _, err = embedder.Embed(ctx, texts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get embeddings: %w", err)
+		}
+	}
+
+	parent := make([]int, len(cards))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	for i := range cards {
+		for j := i + 1; j < len(cards); j++ {
+			if cards[i].Type != cards[j].Type || cards[i].ClozeIndex != cards[j].ClozeIndex {
+				continue
+			}
+			if jaccard(words[i], words[j]) >= opts.Threshold ||
+				(vectors != nil && cosine(vectors[i], vectors[j]) >= opts.EmbeddingThreshold) {
+				parent[find(i)] = find(j)
+			}
+		}
+	}
+
+	groups := make(map[int][]DuplicateCard)
+	var roots []int
+	for i, card := range cards {
+		root := find(i)
+		if _, ok := groups[root]; !ok {
+			roots = append(roots, root)
+		}
+		groups[root] = append(groups[root], card)
+	}
+
+	var clusters []DuplicateCluster
+	for _, root := range roots {
+		group := groups[root]
+		if len(group) < 2 {
+			continue
+		}
+		sort.SliceStable(group, func(i, j int) bool {
+			return betterHistory(group[i], group[j])
+		})
+		clusters = append(clusters, DuplicateCluster{Deck: deck, Cards: group})
+	}
+	return clusters, nil
+}
+
+// cardText is the text a flashcard asks about; a cloze card's deletions are
+// filled in, so its markup doesn't count as words
+func cardText(card storage.Flashcard) string {
+	if card.Type == storage.TypeCloze {
+		return cloze.Reveal(card.Question, card.ClozeIndex, func(s string) string { return s })
+	}
+	return card.Question
+}
+
+// betterHistory reports whether a has a review history more worth keeping
+// than b: more reviews, then a longer interval, then fewer lapses
+func betterHistory(a, b DuplicateCard) bool {
+	if a.Reviews != b.Reviews {
+		return a.Reviews > b.Reviews
+	}
+	if a.RepCount != b.RepCount {
+		return a.RepCount > b.RepCount
+	}
+	if a.Interval != b.Interval {
+		return a.Interval > b.Interval
+	}
+	return a.Lapses < b.Lapses
+}
+
+// cosine is the cosine similarity of two vectors, from -1 to 1
+func cosine(a, b []float64) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
+
+// MergeDuplicates keeps one card of a cluster, the one numbered keep, and
+// archives the others, so they are never studied again. The kept card's
+// wording moves to the card with the best review history, and that card's
+// wording to the chosen one, so the card left in study has both the chosen
+// wording and the schedule and review log of the best history, and no progress
+// is lost. Each archived card keeps its own reviews.
+func MergeDuplicates(store storage.Store, cluster DuplicateCluster, keep int) error {
+	if keep < 0 || keep >= len(cluster.Cards) {
+		return fmt.Errorf("no card %d in the cluster", keep+1)
+	}
+
+	cards := make([]storage.Flashcard, len(cluster.Cards))
+	for i, card := range cluster.Cards {
+		cards[i] = card.Flashcard
+	}
+
+	if keep != 0 {
+		swapWording(&cards[0], &cards[keep])
+		for _, card := range []storage.Flashcard{cards[0], cards[keep]} {
+			if err := store.UpdateFlashcard(card); err != nil {
+				return fmt.Errorf("failed to update flashcard: %w", err)
+			}
+		}
+	}
+
+	return setCardStatus(store, cards[1:], storage.StatusActive, storage.StatusArchived)
+}
+
+// swapWording swaps what two flashcards ask and where they come from, leaving
+// each with its own ID, schedule and review history
+func swapWording(a, b *storage.Flashcard) {
+	a.NoteID, b.NoteID = b.NoteID, a.NoteID
+	a.Type, b.Type = b.Type, a.Type
+	a.Question, b.Question = b.Question, a.Question
+	a.Answer, b.Answer = b.Answer, a.Answer
+	a.ClozeIndex, b.ClozeIndex = b.ClozeIndex, a.ClozeIndex
+	a.Source, b.Source = b.Source, a.Source
+	a.Tags, b.Tags = b.Tags, a.Tags
+	a.Section, b.Section = b.Section, a.Section
+	a.SourceQuote, b.SourceQuote = b.SourceQuote, a.SourceQuote
+	a.SourceStart, b.SourceStart = b.SourceStart, a.SourceStart
+	a.SourceEnd, b.SourceEnd = b.SourceEnd, a.SourceEnd
+}
@@ -0,0 +1,81 @@
+package processor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/valdezdata/md-study/internal/scheduler"
+	"github.com/valdezdata/md-study/internal/storage"
+)
+
+func TestMergeDuplicatesKeepsHistory(t *testing.T) {
+	store := storage.NewMemoryStore()
+	start := time.Now().Add(-30 * 24 * time.Hour)
+
+	if err := store.SaveNote(storage.Note{ID: "n1", FilePath: "/notes/tcp.md", Deck: "net"}); err != nil {
+		t.Fatal(err)
+	}
+	studied := storage.Flashcard{ID: "studied", NoteID: "n1", Question: "What does TCP stand for?", Answer: "Transmission Control Protocol",
+		RepCount: 2, Interval: 6, Repetitions: 2, EaseFactor: 2.5, LastReview: start.Add(24 * time.Hour), NextReview: start.Add(7 * 24 * time.Hour)}
+	worded := storage.Flashcard{ID: "worded", NoteID: "n1", Question: "What does TCP stand for", Answer: "Transmission Control Protocol (TCP)",
+		NextReview: start}
+	for _, card := range []storage.Flashcard{studied, worded} {
+		if err := store.SaveFlashcard(card); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, rating := range []int{scheduler.Good, scheduler.Good} {
+		review := storage.Review{CardID: "studied", Rating: rating, ReviewedAt: start.Add(time.Duration(i) * 24 * time.Hour)}
+		if err := store.AddReview(review); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	clusters, err := FindDuplicates(context.Background(), store, DedupeOptions{Threshold: DefaultDuplicateThreshold})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clusters) != 1 || len(clusters[0].Cards) != 2 || clusters[0].Cards[0].ID != "studied" {
+		t.Fatalf("clusters = %+v, want one with the studied card first", clusters)
+	}
+
+	// Keep the better worded card, which has no history of its own
+	if err := MergeDuplicates(store, clusters[0], 1); err != nil {
+		t.Fatal(err)
+	}
+
+	cards, _ := store.GetAllFlashcards()
+	var active []storage.Flashcard
+	for _, card := range cards {
+		if card.Status == storage.StatusActive {
+			active = append(active, card)
+		}
+	}
+	if len(active) != 1 {
+		t.Fatalf("%d active cards, want 1", len(active))
+	}
+	kept := active[0]
+	if kept.Answer != worded.Answer || kept.Interval != 6 || kept.RepCount != 2 || !kept.NextReview.Equal(studied.NextReview) {
+		t.Errorf("kept card = %+v, want the chosen wording with the studied card's schedule", kept)
+	}
+
+	// Its review log comes with it, so switching its deck to FSRS replays the
+	// right history
+	reviews, _ := store.GetReviews(kept.ID)
+	if len(reviews) != 2 {
+		t.Fatalf("kept card has %d reviews, want 2", len(reviews))
+	}
+	f := scheduler.FSRS{DesiredRetention: 0.9}
+	if !f.NeedsReplay(kept) {
+		t.Fatal("kept card doesn't need replaying")
+	}
+	if replayed := f.Replay(kept, reviews); replayed.Stability <= 0 || replayed.Repetitions != 2 {
+		t.Errorf("replayed stability %v, repetitions %d, want a state from two reviews", replayed.Stability, replayed.Repetitions)
+	}
+
+	// The statistics still count every review once
+	if stats, _ := storage.GetStudyStats(store); stats.TotalReviews != 2 {
+		t.Errorf("TotalReviews = %d, want 2", stats.TotalReviews)
+	}
+}
@@ -0,0 +1,131 @@
+package processor
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/valdezdata/md-study/internal/generator"
+	"github.com/valdezdata/md-study/internal/storage"
+)
+
+// outputTokensPerCard is roughly how long a flashcard is as JSON, with its source quote
+const outputTokensPerCard = 80
+
+// estimate adds up the requests generating would send to one provider and model
+type estimate struct {
+	settings generator.Settings
+	requests int
+	cached   int // Requests already answered in the cache, which cost nothing
+	usage    generator.Usage
+}
+
+// estimateGeneration prints the requests, tokens and cost generating for
+// notes would take, without calling any model. Token counts are estimated
+// from the prompts, and replies from the number of cards asked for.
+func estimateGeneration(notes []storage.Note, cardsByNote map[string][]storage.Flashcard, opts GenerateOptions) error {
+	estimates := make(map[[2]string]*estimate)
+	requests := 0
+
+	for _, note := range notes {
+		chunks, err := noteRequests(note, cardsByNote[note.ID], opts)
+		if err != nil {
+			return fmt.Errorf("failed to write prompts for %s: %w", note.Filename, err)
+		}
+
+		settings := opts.Generators.Settings(note.Deck)
+		key := [2]string{settings.Provider, settings.Model}
+		e, ok := estimates[key]
+		if !ok {
+			e = &estimate{settings: settings}
+			estimates[key] = e
+		}
+
+		for _, r := range chunks {
+			requests++
+			e.requests++
+			if opts.Generators.Cached(note.Deck, r.req) {
+				e.cached++
+				continue
+			}
+			e.usage.InputTokens += estimateTokens(r.req.System) + estimateTokens(r.req.Prompt)
+			e.usage.OutputTokens += r.req.Count * outputTokensPerCard
+		}
+	}
+
+	fmt.Printf("Dry run: generating flashcards for %d notes would send %d requests\n", len(notes), requests)
+
+	var sorted []*estimate
+	for _, e := range estimates {
+		sorted = append(sorted, e)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].settings.Provider+" "+sorted[i].settings.Model < sorted[j].settings.Provider+" "+sorted[j].settings.Model
+	})
+
+	total, priced := 0.0, true
+	for _, e := range sorted {
+		fmt.Printf("  %s: %d requests", modelName(e.settings.Provider, e.settings.Model), e.requests)
+		if e.cached > 0 {
+			fmt.Printf(" (%d cached)", e.cached)
+		}
+		fmt.Printf(", about %d input and %d output tokens, %s\n", e.usage.InputTokens, e.usage.OutputTokens,
+			costString(opts.Generators, e.settings, e.usage, &total, &priced))
+	}
+
+	switch {
+	case priced:
+		fmt.Printf("Estimated cost: about $%.4f\n", total)
+	case total == 0:
+		fmt.Println("Estimated cost: unknown")
+	default:
+		fmt.Printf("Estimated cost: at least $%.4f, not counting models without a known price\n", total)
+	}
+	return nil
+}
+
+// printUsage prints the requests, tokens and cost of the API calls made so far
+func printUsage(generators *generator.Set) {
+	totals := generators.Usage()
+	if len(totals) == 0 {
+		return
+	}
+
+	fmt.Println("Token usage:")
+	total, priced := 0.0, true
+	for _, t := range totals {
+		settings := generator.Settings{Provider: t.Provider, Model: t.Model}
+		fmt.Printf("  %s: %d requests", modelName(t.Provider, t.Model), t.Requests)
+		if t.Cached > 0 {
+			fmt.Printf(" (and %d answered from the cache)", t.Cached)
+		}
+		fmt.Printf(", %d input and %d output tokens, %s\n", t.InputTokens, t.OutputTokens,
+			costString(generators, settings, t.Usage, &total, &priced))
+	}
+	if len(totals) > 1 && priced {
+		fmt.Printf("  Total: $%.4f\n", total)
+	}
+}
+
+// costString describes what usage costs with a model, adding it to total, or
+// clearing priced if the model's price isn't known
+func costString(generators *generator.Set, settings generator.Settings, usage generator.Usage, total *float64, priced *bool) string {
+	if settings.Provider == generator.ProviderOffline || settings.Provider == generator.ProviderReplay {
+		return "free"
+	}
+	price, ok := generators.Price(settings)
+	if !ok {
+		*priced = false
+		return "price unknown (add it to \"prices\" in the config)"
+	}
+	cost := price.Cost(usage)
+	*total += cost
+	return fmt.Sprintf("$%.4f", cost)
+}
+
+// modelName names a provider's model for display
+func modelName(provider, model string) string {
+	if model == "" {
+		return provider
+	}
+	return provider + " " + model
+}
@@ -0,0 +1,169 @@
+package processor
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// frontMatter is the YAML block at the top of a markdown file, as used by
+// Obsidian, Jekyll and Hugo
+type frontMatter struct {
+	Title    string
+	Tags     []string
+	Study    bool           // False when the note opts out with "study: false"
+	Metadata map[string]any // Every other key, such as aliases or dates
+}
+
+// splitFrontMatter separates the front matter of a markdown file from its body.
+// Content without front matter is returned unchanged as the body.
+func splitFrontMatter(content []byte) (frontMatter, []byte, error) {
+	fm := frontMatter{Study: true}
+
+	text := bytes.TrimPrefix(content, []byte("\ufeff"))
+	first, rest, ok := cutLine(text)
+	if !ok || strings.TrimSpace(string(first)) != "---" {
+		return fm, content, nil
+	}
+
+	// The block ends at the next "---" or "..." line
+	var block []byte
+	body := rest
+	closed := false
+	for len(body) > 0 {
+		line, next, _ := cutLine(body)
+		body = next
+		if trimmed := strings.TrimSpace(string(line)); trimmed == "---" || trimmed == "..." {
+			closed = true
+			break
+		}
+		block = append(block, line...)
+		block = append(block, '\n')
+	}
+	if !closed {
+		return fm, content, nil
+	}
+
+	var values map[string]any
+	if err := yaml.Unmarshal(block, &values); err != nil {
+		return fm, content, fmt.Errorf("invalid front matter: %w", err)
+	}
+
+	for key, value := range values {
+		switch strings.ToLower(key) {
+		case "title":
+			fm.Title = parseTitle(value)
+		case "tags", "tag":
+			fm.Tags = append(fm.Tags, parseTags(value)...)
+		case "study":
+			study, err := parseStudy(value)
+			if err != nil {
+				return fm, content, fmt.Errorf("invalid front matter: %s: %w", key, err)
+			}
+			fm.Study = study
+		default:
+			if fm.Metadata == nil {
+				fm.Metadata = make(map[string]any)
+			}
+			fm.Metadata[key] = value
+		}
+	}
+	fm.Tags = uniqueTags(fm.Tags)
+
+	return fm, body, nil
+}
+
+// parseTitle reads the title key, which may be any YAML scalar, such as a
+// number or the date of a daily note; an empty title, a list or a map gives none
+func parseTitle(value any) string {
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case int, int64, uint64, float64, bool:
+		return fmt.Sprint(v)
+	case time.Time:
+		if v.Equal(v.Truncate(24 * time.Hour)) {
+			return v.Format(time.DateOnly)
+		}
+		return v.Format(time.RFC3339)
+	}
+	return ""
+}
+
+// parseStudy reads the study key, which YAML 1.2 only makes a boolean when it
+// is written as true or false; quoted values and yes, no, on and off are
+// strings, and are read as the boolean they stand for. An empty study key
+// leaves the note studied.
+func parseStudy(value any) (bool, error) {
+	switch v := value.(type) {
+	case nil:
+		return true, nil
+	case bool:
+		return v, nil
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "yes", "on":
+			return true, nil
+		case "no", "off":
+			return false, nil
+		}
+		if study, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+			return study, nil
+		}
+	}
+	return false, fmt.Errorf("%v is not true or false", value)
+}
+
+// cutLine splits off the first line of text, without its line ending
+func cutLine(text []byte) (line, rest []byte, found bool) {
+	line, rest, found = bytes.Cut(text, []byte("\n"))
+	return bytes.TrimSuffix(line, []byte("\r")), rest, found
+}
+
+// parseTags accepts tags written as a YAML list or as a single string
+// separated by commas or spaces, with or without a leading "#"
+func parseTags(value any) []string {
+	var tags []string
+	switch v := value.(type) {
+	case []any:
+		for _, item := range v {
+			tags = append(tags, parseTags(item)...)
+		}
+	case string:
+		tags = strings.FieldsFunc(v, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+	case nil:
+	default:
+		tags = []string{fmt.Sprint(v)}
+	}
+	return tags
+}
+
+// uniqueTags strips "#" from tags and drops empty and repeated ones, keeping their order
+func uniqueTags(tags []string) []string {
+	var result []string
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "#")
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		result = append(result, tag)
+	}
+	return result
+}
+
+// noteBody returns a note's content without its front matter
+func noteBody(rawContent string) string {
+	_, body, err := splitFrontMatter([]byte(rawContent))
+	if err != nil {
+		return rawContent
+	}
+	return string(body)
+}
@@ -0,0 +1,73 @@
+package processor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitFrontMatterStudy(t *testing.T) {
+	tests := []struct {
+		value string
+		study bool
+		err   bool
+	}{
+		{"true", true, false},
+		{"false", false, false},
+		{`"false"`, false, false},
+		{"'False'", false, false},
+		{"no", false, false},
+		{`"no"`, false, false},
+		{"off", false, false},
+		{"yes", true, false},
+		{`"1"`, true, false},
+		{"", true, false},
+		{"maybe", false, true},
+		{"3", false, true},
+	}
+
+	for _, tt := range tests {
+		content := "---\ntitle: Go\nstudy: " + tt.value + "\n---\n# Go\n"
+		fm, body, err := splitFrontMatter([]byte(content))
+		if tt.err {
+			if err == nil || !strings.Contains(err.Error(), "study") {
+				t.Errorf("study: %s: error %v, want one naming the field", tt.value, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("study: %s: %v", tt.value, err)
+			continue
+		}
+		if fm.Study != tt.study || fm.Title != "Go" || string(body) != "# Go\n" {
+			t.Errorf("study: %s: study %v, title %q, body %q, want %v, Go and the heading", tt.value, fm.Study, fm.Title, body, tt.study)
+		}
+	}
+}
+
+func TestSplitFrontMatterTitle(t *testing.T) {
+	tests := []struct {
+		value string
+		title string
+	}{
+		{"Go basics", "Go basics"},
+		{`"  Quoted  "`, "Quoted"},
+		{"", ""},
+		{"~", ""},
+		{"42", "42"},
+		{"2024-05-01", "2024-05-01"},
+		{"[a, b]", ""},
+		{"{a: b}", ""},
+	}
+
+	for _, tt := range tests {
+		content := "---\ntitle: " + tt.value + "\n---\n# Go\n"
+		fm, _, err := splitFrontMatter([]byte(content))
+		if err != nil {
+			t.Errorf("title: %s: %v", tt.value, err)
+			continue
+		}
+		if fm.Title != tt.title {
+			t.Errorf("title: %s: title %q, want %q", tt.value, fm.Title, tt.title)
+		}
+	}
+}
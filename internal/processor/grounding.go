@@ -0,0 +1,235 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/valdezdata/md-study/internal/generator"
+	"github.com/valdezdata/md-study/internal/storage"
+)
+
+// Thresholds for matching generated flashcards back to their note
+const (
+	quoteMatchThreshold  = 0.8 // Share of a quote's words found together in the note
+	answerMatchThreshold = 0.6 // Share of an answer's words found in its source passage
+)
+
+// stopWords are left out when comparing an answer with its source, since
+// they support nothing by appearing in both
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "by": true,
+	"for": true, "from": true, "has": true, "have": true, "in": true, "is": true, "it": true, "its": true,
+	"of": true, "on": true, "or": true, "that": true, "the": true, "this": true, "to": true, "was": true,
+	"were": true, "which": true, "with": true,
+}
+
+// noteWord is a word of a note's text and the line it is on, from 1
+type noteWord struct {
+	word string
+	line int
+}
+
+// noteWords splits a note's text into normalized words, remembering their lines
+func noteWords(raw string) []noteWord {
+	var words []noteWord
+	for i, line := range strings.Split(raw, "\n") {
+		for _, word := range strings.Fields(normalizeText(line)) {
+			words = append(words, noteWord{word: word, line: i + 1})
+		}
+	}
+	return words
+}
+
+// locateQuote finds the lines of a note a quote spans, ignoring case,
+// punctuation, markup and line breaks. A quote the model changed a little is
+// still found where most of its words appear together.
+func locateQuote(words []noteWord, quote string) (start, end int, ok bool) {
+	quoteWords := strings.Fields(normalizeText(quote))
+	n := len(quoteWords)
+	if n == 0 || n > len(words) {
+		return 0, 0, false
+	}
+
+	wanted := make(map[string]int)
+	for _, word := range quoteWords {
+		wanted[word]++
+	}
+
+	best, bestAt := 0, -1
+	for i := 0; i+n <= len(words); i++ {
+		exact := true
+		for j, word := range quoteWords {
+			if words[i+j].word != word {
+				exact = false
+				break
+			}
+		}
+		if exact {
+			return words[i].line, words[i+n-1].line, true
+		}
+
+		remaining := make(map[string]int, len(wanted))
+		for word, count := range wanted {
+			remaining[word] = count
+		}
+		found := 0
+		for _, w := range words[i : i+n] {
+			if remaining[w.word] > 0 {
+				remaining[w.word]--
+				found++
+			}
+		}
+		if found > best {
+			best, bestAt = found, i
+		}
+	}
+
+	if bestAt < 0 || float64(best) < quoteMatchThreshold*float64(n) {
+		return 0, 0, false
+	}
+	return words[bestAt].line, words[bestAt+n-1].line, true
+}
+
+// contentWords returns the distinct words of a text that aren't stop words
+func contentWords(text string) map[string]bool {
+	words := wordSet(text)
+	for word := range words {
+		if stopWords[word] {
+			delete(words, word)
+		}
+	}
+	return words
+}
+
+// supported reports whether most of the words of a card's answer appear in
+// the lines of the note from start to end. Cloze cards are checked as a whole,
+// since their text is what they claim. An answer with no words but stop words
+// claims nothing that can be checked, so it is never supported.
+func supported(words []noteWord, card storage.Flashcard, start, end int) bool {
+	claim := card.Answer
+	if card.Type == storage.TypeCloze {
+		claim = cardText(card)
+	}
+	claimed := contentWords(claim)
+	if len(claimed) == 0 {
+		return false
+	}
+
+	passage := make(map[string]bool)
+	for _, w := range words {
+		if w.line >= start && w.line <= end {
+			passage[w.word] = true
+		}
+	}
+
+	found := 0
+	for word := range claimed {
+		if passage[word] {
+			found++
+		}
+	}
+	return float64(found) >= answerMatchThreshold*float64(len(claimed))
+}
+
+// groundCard looks for the passage of a note a card is based on, the model's
+// quote, and returns its lines and whether the answer is supported there; an
+// answer on a line next to the quote, in the same paragraph, counts. A card
+// whose quote can't be found is not supported, since a line that merely
+// shares the answer's words doesn't show the note says it.
+func groundCard(words []noteWord, card storage.Flashcard) (start, end int, ok bool) {
+	start, end, found := locateQuote(words, card.SourceQuote)
+	if !found {
+		return 0, 0, false
+	}
+	return start, end, supported(words, card, start-1, end+1)
+}
+
+// groundCards records the passage of the note each card is based on, and
+// marks the cards whose answer can't be found there as unverified. If verify
+// is set, the model is asked about those cards before they are marked, and
+// the ones it finds supported, with a quote that can be found, are kept; if
+// asking fails, the cards are marked unverified all the same.
+func groundCards(ctx context.Context, gen generator.Generator, note storage.Note, source string, cards []storage.Flashcard, verify bool, logf func(format string, args ...any)) {
+	words := noteWords(note.RawContent)
+
+	var doubtful []int
+	for i := range cards {
+		start, end, ok := groundCard(words, cards[i])
+		cards[i].SourceStart, cards[i].SourceEnd = start, end
+		if !ok {
+			doubtful = append(doubtful, i)
+		}
+	}
+
+	if verify && len(doubtful) > 0 {
+		verdicts, err := verifyCards(ctx, gen, note, source, cards, doubtful)
+		if err != nil {
+			logf("%s: %v", note.Filename, err)
+		}
+
+		var still []int
+		for k, i := range doubtful {
+			if err != nil || !verdicts[k].Supported {
+				still = append(still, i)
+				continue
+			}
+			start, end, found := locateQuote(words, verdicts[k].Quote)
+			if !found {
+				still = append(still, i)
+				continue
+			}
+			cards[i].SourceQuote = strings.TrimSpace(verdicts[k].Quote)
+			cards[i].SourceStart, cards[i].SourceEnd = start, end
+		}
+		doubtful = still
+	}
+
+	for _, i := range doubtful {
+		cards[i].Status = storage.StatusUnverified
+	}
+}
+
+// verifyCards asks the model whether the notes support the answers of some
+// cards, in one request
+func verifyCards(ctx context.Context, gen generator.Generator, note storage.Note, source string, cards []storage.Flashcard, which []int) ([]generator.Verdict, error) {
+	var prompt strings.Builder
+	prompt.WriteString("For each flashcard below, say whether the notes state its answer. ")
+	prompt.WriteString("Only count what the notes say, not what you know, and quote the passage that states it word for word.\n\n")
+	fmt.Fprintf(&prompt, "Notes:\n%s\n\nFlashcards:\n", source)
+	for k, i := range which {
+		card := cards[i]
+		if card.Type == storage.TypeCloze {
+			fmt.Fprintf(&prompt, "%d. %s\n", k+1, cardText(card))
+		} else {
+			fmt.Fprintf(&prompt, "%d. Q: %s\n   A: %s\n", k+1, card.Question, card.Answer)
+		}
+	}
+	fmt.Fprintf(&prompt, "\nReply with a JSON object with a \"verdicts\" list of %d objects, one for each flashcard in order, each with \"supported\" (true or false) and \"quote\" (empty if unsupported).", len(which))
+
+	resp, err := gen.Generate(ctx, generator.Request{
+		System:   systemPrompt,
+		Prompt:   prompt.String(),
+		Schema:   &generator.VerdictSchema,
+		NoteHash: note.ContentHash,
+		Validate: func(content string) error {
+			_, err := generator.ParseVerdicts(content, len(which))
+			return err
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify flashcards: %w", err)
+	}
+
+	verdicts, err := generator.ParseVerdicts(resp.Content, len(which))
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify flashcards: %w", err)
+	}
+	return verdicts, nil
+}
+
+// GetUnverifiedCards returns every flashcard whose answer couldn't be found in
+// its note, waiting to be accepted or deleted
+func GetUnverifiedCards(store storage.Store) ([]NewCard, error) {
+	return getCardsWithStatus(store, storage.StatusUnverified)
+}
@@ -0,0 +1,44 @@
+package processor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/valdezdata/md-study/internal/storage"
+)
+
+func TestGroundCards(t *testing.T) {
+	note := storage.Note{Filename: "http.md", RawContent: "# HTTP\n\nHTTPS uses port 443 by default.\nPlain HTTP uses port 80.\n\nTLS encrypts the connection.\n"}
+
+	tests := []struct {
+		name       string
+		card       storage.Flashcard
+		start, end int
+		verified   bool
+	}{
+		{"quote and answer found", storage.Flashcard{Answer: "443", SourceQuote: "HTTPS uses port 443 by default."}, 3, 3, true},
+		{"quote reworded a little", storage.Flashcard{Answer: "Port 443", SourceQuote: "HTTPS uses port 443 by default"}, 3, 3, true},
+		{"answer on the next line", storage.Flashcard{Answer: "80", SourceQuote: "HTTPS uses port 443 by default."}, 3, 3, true},
+		{"answer not in the passage", storage.Flashcard{Answer: "UDP 8443", SourceQuote: "TLS encrypts the connection."}, 6, 6, false},
+		{"quote not in the note", storage.Flashcard{Answer: "443", SourceQuote: "QUIC runs HTTPS over UDP port 443."}, 0, 0, false},
+		{"no quote", storage.Flashcard{Answer: "port 80"}, 0, 0, false},
+		{"answer of stop words only", storage.Flashcard{Answer: "It is.", SourceQuote: "TLS encrypts the connection."}, 6, 6, false},
+		{"cloze text found", storage.Flashcard{Type: storage.TypeCloze, Question: "TLS {{c1::encrypts}} the connection.", ClozeIndex: 1,
+			SourceQuote: "TLS encrypts the connection."}, 6, 6, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cards := []storage.Flashcard{tt.card}
+			groundCards(context.Background(), nil, note, note.RawContent, cards, false, t.Logf)
+
+			got := cards[0]
+			if got.SourceStart != tt.start || got.SourceEnd != tt.end {
+				t.Errorf("lines %d-%d, want %d-%d", got.SourceStart, got.SourceEnd, tt.start, tt.end)
+			}
+			if verified := got.Status != storage.StatusUnverified; verified != tt.verified {
+				t.Errorf("verified = %v, want %v", verified, tt.verified)
+			}
+		})
+	}
+}
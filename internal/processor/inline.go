@@ -0,0 +1,252 @@
+package processor
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/valdezdata/md-study/internal/cloze"
+	"github.com/valdezdata/md-study/internal/storage"
+)
+
+var (
+	// inlineSeparator splits "Question :: Answer"; the spaces keep "std::vector" from matching
+	inlineSeparator = regexp.MustCompile(`\s::\s`)
+	listMarker      = regexp.MustCompile(`^\s*(?:[-*+]|\d{1,9}[.)])\s+`)
+)
+
+// parseInlineCards finds the flashcards written directly in a note:
+//
+//	Question :: Answer
+//
+//	A question that may span
+//	several lines
+//	?
+//	Its answer
+//
+//	Q: Question
+//	A: Answer
+//
+// The "::" form also works in list items. Paragraphs and list items with no
+// such card that contain cloze deletions, "{{c1::like this}}" or "==this==",
+// become one cloze card per deletion index. Code blocks are never searched.
+func parseInlineCards(note storage.Note) []storage.Flashcard {
+	var cards []storage.Flashcard
+	seen := make(map[string]bool)
+	add := func(card storage.Flashcard) {
+		card.ID = inlineCardID(note.ID, card)
+		if seen[card.ID] {
+			return
+		}
+		seen[card.ID] = true
+
+		card.Tags = note.Tags
+		card.Source = storage.SourceInline
+		cards = append(cards, card)
+	}
+
+	for _, s := range parseSections(note.RawContent) {
+		lines := strings.Split(s.Content, "\n")
+
+		for _, b := range s.Blocks {
+			if b.Kind != blockParagraph && b.Kind != blockList {
+				continue
+			}
+
+			blockLines := lines[b.StartLine-s.StartLine : b.EndLine-s.StartLine+1]
+			pairs := parseInlineBlock(b.Kind, blockLines)
+			for _, qa := range pairs {
+				add(storage.Flashcard{
+					NoteID:     note.ID,
+					Type:       storage.TypeBasic,
+					Question:   qa[0],
+					Answer:     qa[1],
+					Section:    s.Path,
+					NextReview: time.Now(),
+				})
+			}
+			if len(pairs) > 0 {
+				continue
+			}
+
+			for _, text := range clozeTexts(b.Kind, blockLines) {
+				for _, card := range clozeCards(note.ID, text) {
+					card.Section = s.Path
+					add(card)
+				}
+			}
+		}
+	}
+
+	return cards
+}
+
+// parseInlineBlock returns the question and answer of each card in a block
+func parseInlineBlock(kind blockKind, lines []string) [][2]string {
+	if kind == blockParagraph {
+		for i, line := range lines {
+			if strings.TrimSpace(line) == "?" {
+				return qaPair(strings.Join(trimAll(lines[:i]), "\n"), strings.Join(trimAll(lines[i+1:]), "\n"))
+			}
+		}
+
+		if cards := parseQABlock(lines); len(cards) > 0 {
+			return cards
+		}
+	}
+
+	var cards [][2]string
+	for _, line := range lines {
+		if kind == blockList {
+			line = listMarker.ReplaceAllString(line, "")
+		}
+		if loc := inlineSeparator.FindStringIndex(line); loc != nil {
+			cards = append(cards, qaPair(line[:loc[0]], line[loc[1]:])...)
+		}
+	}
+	return cards
+}
+
+// parseQABlock reads "Q:" and "A:" lines; lines after either continue it
+func parseQABlock(lines []string) [][2]string {
+	var cards [][2]string
+	var question, answer []string
+	inAnswer := false
+
+	flush := func() {
+		if len(question) > 0 && len(answer) > 0 {
+			cards = append(cards, qaPair(strings.Join(question, "\n"), strings.Join(answer, "\n"))...)
+		}
+		question, answer, inAnswer = nil, nil, false
+	}
+
+	for _, line := range trimAll(lines) {
+		switch {
+		case strings.HasPrefix(line, "Q:"):
+			flush()
+			question = append(question, strings.TrimSpace(strings.TrimPrefix(line, "Q:")))
+		case strings.HasPrefix(line, "A:") && len(question) > 0:
+			inAnswer = true
+			answer = append(answer, strings.TrimSpace(strings.TrimPrefix(line, "A:")))
+		case inAnswer:
+			answer = append(answer, line)
+		case len(question) > 0:
+			question = append(question, line)
+		}
+	}
+	flush()
+
+	return cards
+}
+
+// clozeTexts returns the texts in a block that may hold cloze deletions: a
+// whole paragraph, or each item of a list
+func clozeTexts(kind blockKind, lines []string) []string {
+	if kind == blockParagraph {
+		return []string{strings.Join(trimAll(lines), "\n")}
+	}
+
+	var texts []string
+	for _, line := range lines {
+		if listMarker.MatchString(line) {
+			texts = append(texts, strings.TrimSpace(listMarker.ReplaceAllString(line, "")))
+		}
+	}
+	return texts
+}
+
+// clozeCards returns a cloze card for each deletion index in text
+func clozeCards(noteID, text string) []storage.Flashcard {
+	var cards []storage.Flashcard
+	for _, index := range cloze.Indexes(text) {
+		cards = append(cards, storage.Flashcard{
+			NoteID:     noteID,
+			Type:       storage.TypeCloze,
+			Question:   text,
+			Answer:     cloze.Answer(text, index),
+			ClozeIndex: index,
+			NextReview: time.Now(),
+		})
+	}
+	return cards
+}
+
+// qaPair returns a card for a question and answer, or none if either is empty
+func qaPair(question, answer string) [][2]string {
+	question, answer = strings.TrimSpace(question), strings.TrimSpace(answer)
+	if question == "" || answer == "" {
+		return nil
+	}
+	return [][2]string{{question, answer}}
+}
+
+// inlineCardID derives a card's ID from its note and its text as written, so
+// importing the same card again finds it instead of creating a duplicate, and
+// cards that differ only in punctuation or markup stay apart
+func inlineCardID(noteID string, card storage.Flashcard) string {
+	key := fmt.Sprintf("%s\n%s\n%s\n%d", noteID, strings.TrimSpace(card.Question), strings.TrimSpace(card.Answer), card.ClozeIndex)
+	return "inline-" + contentHash([]byte(key))[:16]
+}
+
+// syncInlineCards makes a note's existing inline flashcards match the cards
+// written in it. A card whose question is unchanged, or was only reworded, keeps
+// its ID, schedule and history; cards no longer in the note are deleted. The
+// returned merge holds only the cards that were saved or deleted.
+func syncInlineCards(store storage.Store, note storage.Note, existing []storage.Flashcard) (cardMerge, error) {
+	parsed := parseInlineCards(note)
+
+	// Cards whose question is the same match by ID, the rest by similarity
+	byID := make(map[string]int, len(existing))
+	for i, card := range existing {
+		byID[card.ID] = i
+	}
+
+	var merge cardMerge
+	var unmatched []storage.Flashcard
+	used := make(map[string]bool)
+	for _, card := range parsed {
+		i, ok := byID[card.ID]
+		if !ok {
+			unmatched = append(unmatched, card)
+			continue
+		}
+		used[card.ID] = true
+
+		kept := existing[i]
+		kept.Question, kept.Answer, kept.Tags, kept.Section = card.Question, card.Answer, card.Tags, card.Section
+		merge.Keep = append(merge.Keep, kept)
+	}
+
+	var rest []storage.Flashcard
+	for _, card := range existing {
+		if !used[card.ID] {
+			rest = append(rest, card)
+		}
+	}
+	reworded := mergeCards(rest, unmatched)
+	merge.Keep = append(merge.Keep, reworded.Keep...)
+	merge.Add = reworded.Add
+	merge.Remove = reworded.Remove
+
+	// Only write the cards that changed
+	current := make(map[string]storage.Flashcard, len(existing))
+	for _, card := range existing {
+		current[card.ID] = card
+	}
+	changed := merge.Keep[:0:0]
+	for _, card := range merge.Keep {
+		old := current[card.ID]
+		if old.Question != card.Question || old.Answer != card.Answer ||
+			!slices.Equal(old.Tags, card.Tags) || !slices.Equal(old.Section, card.Section) {
+			changed = append(changed, card)
+		}
+	}
+	merge.Keep = changed
+
+	if err := applyMerge(store, merge); err != nil {
+		return merge, fmt.Errorf("failed to update inline flashcards: %w", err)
+	}
+	return merge, nil
+}
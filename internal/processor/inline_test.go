@@ -0,0 +1,67 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/valdezdata/md-study/internal/storage"
+)
+
+func TestInlineCardIDsKeepDistinctCardsApart(t *testing.T) {
+	note := storage.Note{ID: "n1", RawContent: "What is Go? :: A language\n\nWhat is Go :: A game\n\n" +
+		"{{c1::Paris}} is the capital of France.\n\n{{c2::Paris}} is the capital of France.\n"}
+
+	cards := parseInlineCards(note)
+	if len(cards) != 4 {
+		t.Fatalf("%d cards, want 4: %+v", len(cards), cards)
+	}
+	seen := make(map[string]bool)
+	for _, card := range cards {
+		if seen[card.ID] {
+			t.Errorf("two cards have ID %s", card.ID)
+		}
+		seen[card.ID] = true
+	}
+
+	// The same text gives the same IDs
+	for i, card := range parseInlineCards(note) {
+		if card.ID != cards[i].ID {
+			t.Errorf("card %d: ID %s, then %s", i, cards[i].ID, card.ID)
+		}
+	}
+}
+
+func TestSyncInlineCardsKeepsEditedCard(t *testing.T) {
+	store := storage.NewMemoryStore()
+	note := storage.Note{ID: "n1", RawContent: "What closes a channel? :: close\n"}
+
+	merge, err := syncInlineCards(store, note, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(merge.Add) != 1 {
+		t.Fatalf("%d cards added, want 1", len(merge.Add))
+	}
+	card := merge.Add[0]
+	card.RepCount, card.Interval = 3, 6
+	if err := store.UpdateFlashcard(card); err != nil {
+		t.Fatal(err)
+	}
+
+	// Editing the answer updates the card, keeping its ID and schedule
+	note.RawContent = "What closes a channel? :: The close builtin\n"
+	existing, _ := store.GetAllFlashcards()
+	merge, err = syncInlineCards(store, note, existing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(merge.Add) != 0 || len(merge.Remove) != 0 || len(merge.Keep) != 1 {
+		t.Fatalf("added %d, removed %d, kept %d, want 0, 0 and 1", len(merge.Add), len(merge.Remove), len(merge.Keep))
+	}
+	got, err := store.GetFlashcard(card.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Answer != "The close builtin" || got.RepCount != 3 || got.Interval != 6 {
+		t.Errorf("card = %q, %d reps, %v days, want the new answer with its schedule", got.Answer, got.RepCount, got.Interval)
+	}
+}
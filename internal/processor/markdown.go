@@ -2,49 +2,403 @@ package processor
 
 import (
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/valdezdata/md-study/internal/storage"
 )
 
-// ImportMarkdownFiles processes all markdown files in a directory
-func ImportMarkdownFiles(dirPath string) error {
-	files, err := ioutil.ReadDir(dirPath)
+// ImportOptions controls which files an import picks up
+type ImportOptions struct {
+	Deck    string   // Deck the imported notes belong to
+	Include []string // Only import files matching one of these globs, if any are given
+	Exclude []string // Skip files and directories matching any of these globs
+}
+
+// ImportSummary counts what happened to each markdown file during an import
+type ImportSummary struct {
+	New       int
+	Updated   int
+	Unchanged int
+	Moved     int
+	Skipped   int
+
+	// Deleted counts notes whose source file has disappeared. Their flashcards,
+	// and those of notes that opted out with "study: false", are marked orphaned
+	// and counted in Orphaned.
+	Deleted  int
+	Orphaned int
+
+	// Changed counts updated notes whose flashcards were generated from an older version
+	Changed int
+
+	// Inline flashcards written in the notes that were created, updated or deleted
+	CardsAdded   int
+	CardsUpdated int
+	CardsRemoved int
+
+	SkippedFiles []string // Each skipped file with the reason it was skipped
+}
+
+// importStatus describes what an import did with a single file
+type importStatus int
+
+const (
+	statusNew importStatus = iota
+	statusUpdated
+	statusUnchanged
+	statusMoved
+)
+
+// ImportMarkdownFiles processes all markdown files below a directory, descending
+// into subdirectories. Patterns use gitignore syntax and may contain "**"; a
+// .mdstudyignore file in any directory adds exclude patterns for that directory.
+//
+// YAML front matter is parsed into the note's title, tags and metadata. Files
+// with "study: false" in their front matter are skipped, and notes imported
+// from them before are treated like deleted ones.
+//
+// Notes whose files were deleted from the directory have their flashcards marked
+// orphaned. A new file with the same content as a deleted or orphaned note is
+// treated as that note having moved, so it keeps its ID and flashcards.
+func ImportMarkdownFiles(store storage.Store, dirPath string, opts ImportOptions) (ImportSummary, error) {
+	var summary ImportSummary
+
+	root, err := filepath.Abs(dirPath)
+	if err != nil {
+		return summary, fmt.Errorf("failed to resolve directory: %w", err)
+	}
+
+	var rules []ignoreRule
+	for _, pattern := range opts.Exclude {
+		if rule, ok := parseIgnoreRule("", pattern); ok {
+			rules = append(rules, rule)
+		}
+	}
+
+	w := &walker{include: opts.Include, visited: make(map[string]bool)}
+	if err := w.walk(root, rules); err != nil {
+		return summary, err
+	}
+
+	notes, err := store.GetAllNotes()
 	if err != nil {
-		return fmt.Errorf("failed to read directory: %w", err)
+		return summary, fmt.Errorf("failed to get notes: %w", err)
+	}
+
+	// Notes imported before paths were made absolute are stored relative to
+	// the directory md-study was run from
+	existing := make(map[string]storage.Note, len(notes))
+	for _, note := range notes {
+		existing[absPath(note.FilePath)] = note
 	}
 
+	cards, err := store.GetAllFlashcards()
+	if err != nil {
+		return summary, fmt.Errorf("failed to get flashcards: %w", err)
+	}
+
+	cardsByNote := make(map[string][]storage.Flashcard)
+	for _, card := range cards {
+		cardsByNote[card.NoteID] = append(cardsByNote[card.NoteID], card)
+	}
+
+	// Read every file first so missing notes can be matched to moved files by content
+	type fileContent struct {
+		markdownFile
+		content []byte
+		hash    string
+		fm      frontMatter
+	}
+
+	var files []fileContent
+	var optedOut []markdownFile
+	seen := make(map[string]bool)
+	for _, file := range w.files {
+		content, err := os.ReadFile(file.path)
+		if err != nil {
+			w.skip(file.rel, "unreadable")
+			continue
+		}
+		seen[file.path] = true
+
+		fm, _, err := splitFrontMatter(content)
+		if err != nil {
+			w.skip(file.rel, err.Error())
+			continue
+		}
+		if !fm.Study {
+			w.skip(file.rel, "study: false in front matter")
+			optedOut = append(optedOut, file)
+			continue
+		}
+
+		files = append(files, fileContent{file, content, contentHash(content), fm})
+	}
+
+	// A note whose file is being imported is never matched to another file by content
+	claimed := make(map[string]bool)
 	for _, file := range files {
-		if !file.IsDir() && strings.HasSuffix(strings.ToLower(file.Name()), ".md") {
-			filePath := filepath.Join(dirPath, file.Name())
-			if err := processMarkdownFile(filePath); err != nil {
-				return fmt.Errorf("failed to process file %s: %w", file.Name(), err)
+		if note, found := existing[file.path]; found {
+			claimed[note.ID] = true
+		}
+	}
+
+	// Missing notes are ones under this directory whose file no longer exists;
+	// notes already orphaned by an earlier import may turn up anywhere
+	missing := make(map[string][]storage.Note)
+	for _, note := range notes {
+		if claimed[note.ID] {
+			continue
+		}
+		path := absPath(note.FilePath)
+		if note.Orphaned || (withinDir(root, path) && !seen[path] && !fileExists(path)) {
+			hash := note.ContentHash
+			if hash == "" {
+				hash = contentHash([]byte(note.RawContent))
 			}
+			missing[hash] = append(missing[hash], note)
 		}
 	}
 
-	return nil
+	for _, file := range files {
+		note, found := existing[file.path]
+
+		moved := false
+		if !found && len(missing[file.hash]) > 0 {
+			note = missing[file.hash][0]
+			missing[file.hash] = missing[file.hash][1:]
+			found, moved = true, true
+		}
+
+		status, saved, err := processMarkdownFile(store, file.path, file.content, file.fm, opts.Deck, note, found)
+		if err != nil {
+			return summary, fmt.Errorf("failed to process file %s: %w", file.rel, err)
+		}
+
+		// Flashcards carry their note's tags
+		if found && !slices.Equal(note.Tags, saved.Tags) {
+			if err := setCardTags(store, cardsByNote[saved.ID], saved.Tags); err != nil {
+				return summary, err
+			}
+		}
+
+		// A note that was orphaned is back, so its cards are studied again
+		if note.Orphaned {
+			if err := setCardStatus(store, cardsByNote[note.ID], storage.StatusOrphaned, storage.StatusActive); err != nil {
+				return summary, err
+			}
+		}
+
+		var inline []storage.Flashcard
+		generated := false
+		for _, card := range cardsByNote[saved.ID] {
+			if card.Source == storage.SourceInline {
+				inline = append(inline, card)
+			} else {
+				generated = true
+			}
+		}
+
+		merge, err := syncInlineCards(store, saved, inline)
+		if err != nil {
+			return summary, err
+		}
+		summary.CardsAdded += len(merge.Add)
+		summary.CardsUpdated += len(merge.Keep)
+		summary.CardsRemoved += len(merge.Remove)
+
+		if moved {
+			status = statusMoved
+		}
+		if status == statusUpdated && generated && noteChanged(saved) {
+			summary.Changed++
+		}
+
+		switch status {
+		case statusNew:
+			summary.New++
+		case statusUpdated:
+			summary.Updated++
+		case statusUnchanged:
+			summary.Unchanged++
+		case statusMoved:
+			summary.Moved++
+		}
+	}
+
+	// Notes that opted out of study are set aside like deleted ones
+	for _, file := range optedOut {
+		note, found := existing[file.path]
+		if !found || note.Orphaned {
+			continue
+		}
+
+		orphaned, err := orphanNote(store, note, cardsByNote[note.ID])
+		if err != nil {
+			return summary, err
+		}
+		summary.Orphaned += orphaned
+	}
+
+	// Whatever is still missing was deleted
+	for _, notes := range missing {
+		for _, note := range notes {
+			if note.Orphaned {
+				continue
+			}
+
+			orphaned, err := orphanNote(store, note, cardsByNote[note.ID])
+			if err != nil {
+				return summary, err
+			}
+			summary.Deleted++
+			summary.Orphaned += orphaned
+		}
+	}
+
+	summary.SkippedFiles = w.skipped
+	summary.Skipped = len(w.skipped)
+
+	return summary, nil
 }
 
-// processMarkdownFile reads a markdown file and extracts content for flashcards
-func processMarkdownFile(filePath string) error {
-	content, err := os.ReadFile(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+// processMarkdownFile saves a markdown file's content as a note, reusing the
+// ID of the existing note for the same file so its flashcards stay attached
+func processMarkdownFile(store storage.Store, filePath string, content []byte, fm frontMatter, deck string, existing storage.Note, found bool) (importStatus, storage.Note, error) {
+	hash := contentHash(content)
+
+	// Notes imported before hashing was added get one from their stored content
+	existingHash := existing.ContentHash
+	if found && existingHash == "" {
+		existingHash = contentHash([]byte(existing.RawContent))
 	}
 
-	// For now, just store the raw content
-	// In a more advanced version, you'd parse the markdown and extract key concepts
+	unchanged := found && existingHash == hash
+	if unchanged && existing.ContentHash != "" && existing.Deck == deck && existing.FilePath == filePath && !existing.Orphaned &&
+		sameFrontMatter(existing, fm) {
+		return statusUnchanged, existing, nil
+	}
+
+	// The raw content is kept as it is, front matter included, so line numbers
+	// match the file; generation parses it into sections
 	note := storage.Note{
-		FilePath:   filePath,
-		Filename:   filepath.Base(filePath),
-		RawContent: string(content),
-		LastImport: time.Now(),
+		FilePath:    filePath,
+		Filename:    filepath.Base(filePath),
+		Deck:        deck,
+		Title:       fm.Title,
+		Tags:        fm.Tags,
+		Metadata:    fm.Metadata,
+		RawContent:  string(content),
+		ContentHash: hash,
+		LastImport:  time.Now(),
 	}
 
-	return storage.SaveNote(note)
+	// New notes get their ID here so their inline flashcards can refer to it
+	status := statusNew
+	note.ID = uuid.New().String()
+	if found {
+		note.ID = existing.ID
+		note.Flashcards = existing.Flashcards
+		note.CardsHash = existing.CardsHash
+		if note.CardsHash == "" {
+			// Cards of older notes were generated from whatever content was stored
+			note.CardsHash = existingHash
+		}
+
+		status = statusUpdated
+		if unchanged && existing.Deck == deck {
+			status = statusUnchanged
+		}
+	}
+
+	if err := store.SaveNote(note); err != nil {
+		return 0, note, err
+	}
+
+	return status, note, nil
+}
+
+// sameFrontMatter reports whether a note already holds the parsed front matter;
+// notes imported before front matter was parsed don't
+func sameFrontMatter(note storage.Note, fm frontMatter) bool {
+	return note.Title == fm.Title && slices.Equal(note.Tags, fm.Tags) && len(note.Metadata) == len(fm.Metadata)
+}
+
+// orphanNote handles a note whose file was deleted: its active flashcards are
+// marked orphaned, and a note with no flashcards is simply removed. It returns
+// the number of flashcards orphaned.
+func orphanNote(store storage.Store, note storage.Note, cards []storage.Flashcard) (int, error) {
+	if len(cards) == 0 {
+		if err := store.DeleteNote(note.ID); err != nil {
+			return 0, fmt.Errorf("failed to delete note: %w", err)
+		}
+		return 0, nil
+	}
+
+	note.Orphaned = true
+	if err := store.SaveNote(note); err != nil {
+		return 0, fmt.Errorf("failed to update note: %w", err)
+	}
+
+	orphaned := 0
+	for _, card := range cards {
+		if card.Status == storage.StatusActive {
+			orphaned++
+		}
+	}
+
+	return orphaned, setCardStatus(store, cards, storage.StatusActive, storage.StatusOrphaned)
+}
+
+// setCardStatus moves the cards with status from to status to, updating the slice as well
+func setCardStatus(store storage.Store, cards []storage.Flashcard, from, to string) error {
+	for i := range cards {
+		if cards[i].Status != from {
+			continue
+		}
+		cards[i].Status = to
+		if err := store.UpdateFlashcard(cards[i]); err != nil {
+			return fmt.Errorf("failed to update flashcard: %w", err)
+		}
+	}
+	return nil
+}
+
+// setCardTags gives cards a new set of tags, updating the slice as well
+func setCardTags(store storage.Store, cards []storage.Flashcard, tags []string) error {
+	for i := range cards {
+		cards[i].Tags = tags
+		if err := store.UpdateFlashcard(cards[i]); err != nil {
+			return fmt.Errorf("failed to update flashcard: %w", err)
+		}
+	}
+	return nil
+}
+
+// absPath makes a stored note path absolute, leaving it as it is if that fails
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// withinDir reports whether path is an absolute path inside dir
+func withinDir(dir, path string) bool {
+	if !filepath.IsAbs(path) {
+		return false
+	}
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// fileExists reports whether anything exists at path
+func fileExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
 }
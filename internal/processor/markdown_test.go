@@ -0,0 +1,105 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/valdezdata/md-study/internal/storage"
+)
+
+// writeNote writes a markdown file below dir, creating its directories
+func writeNote(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestImportOrphanedNoteReturnsWithCopy(t *testing.T) {
+	dir := t.TempDir()
+	store := storage.NewMemoryStore()
+	content := "# Go\n\nGo has goroutines.\n"
+
+	path := writeNote(t, dir, "go.md", content)
+	if _, err := ImportMarkdownFiles(store, dir, ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	notes, _ := store.GetAllNotes()
+	noteID := notes[0].ID
+	if err := store.SaveFlashcard(storage.Flashcard{ID: "c1", NoteID: noteID, Question: "Q", Answer: "A"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Deleting the file orphans the note
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	summary, err := ImportMarkdownFiles(store, dir, ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Deleted != 1 || summary.Orphaned != 1 {
+		t.Fatalf("deleted %d, orphaned %d, want 1 and 1", summary.Deleted, summary.Orphaned)
+	}
+
+	// The file comes back along with a copy, which sorts first: the note goes
+	// back to its own file, and the copy becomes a new note
+	writeNote(t, dir, "go.md", content)
+	writeNote(t, dir, "a/copy.md", content)
+	summary, err = ImportMarkdownFiles(store, dir, ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.New != 1 || summary.Moved != 0 {
+		t.Errorf("new %d, moved %d, want 1 and 0", summary.New, summary.Moved)
+	}
+
+	notes, _ = store.GetAllNotes()
+	if len(notes) != 2 {
+		t.Fatalf("%d notes, want 2", len(notes))
+	}
+	for _, note := range notes {
+		if note.Orphaned {
+			t.Errorf("note %s is still orphaned", note.FilePath)
+		}
+		if (note.ID == noteID) != (note.FilePath == path) {
+			t.Errorf("note %s has ID %s; the original note is %s", note.FilePath, note.ID, noteID)
+		}
+	}
+	if card, _ := store.GetFlashcard("c1"); card.Status != storage.StatusActive {
+		t.Errorf("card status = %q, want %q", card.Status, storage.StatusActive)
+	}
+}
+
+func TestImportOrphansRelativePathNotes(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	store := storage.NewMemoryStore()
+
+	// A note imported before paths were made absolute
+	writeNote(t, dir, "notes/kept.md", "# Kept\n")
+	note := storage.Note{ID: "old", FilePath: filepath.Join("notes", "gone.md"), Filename: "gone.md", RawContent: "# Gone\n"}
+	if err := store.SaveNote(note); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveFlashcard(storage.Flashcard{ID: "c1", NoteID: "old", Question: "Q", Answer: "A"}); err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := ImportMarkdownFiles(store, "notes", ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Deleted != 1 || summary.Orphaned != 1 {
+		t.Errorf("deleted %d, orphaned %d, want 1 and 1", summary.Deleted, summary.Orphaned)
+	}
+	if card, _ := store.GetFlashcard("c1"); card.Status != storage.StatusOrphaned {
+		t.Errorf("card status = %q, want %q", card.Status, storage.StatusOrphaned)
+	}
+}
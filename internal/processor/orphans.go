@@ -0,0 +1,70 @@
+package processor
+
+import (
+	"fmt"
+
+	"github.com/valdezdata/md-study/internal/storage"
+)
+
+// OrphanedNote is a note whose source file was deleted, with its orphaned flashcards
+type OrphanedNote struct {
+	Note  storage.Note
+	Cards []storage.Flashcard
+}
+
+// GetOrphanedNotes returns every note with flashcards still waiting to be archived or deleted
+func GetOrphanedNotes(store storage.Store) ([]OrphanedNote, error) {
+	notes, err := store.GetAllNotes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notes: %w", err)
+	}
+
+	cards, err := store.GetAllFlashcards()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get flashcards: %w", err)
+	}
+
+	orphanedCards := make(map[string][]storage.Flashcard)
+	for _, card := range cards {
+		if card.Status == storage.StatusOrphaned {
+			orphanedCards[card.NoteID] = append(orphanedCards[card.NoteID], card)
+		}
+	}
+
+	var orphans []OrphanedNote
+	for _, note := range notes {
+		if note.Orphaned && len(orphanedCards[note.ID]) > 0 {
+			orphans = append(orphans, OrphanedNote{Note: note, Cards: orphanedCards[note.ID]})
+		}
+	}
+
+	return orphans, nil
+}
+
+// ArchiveOrphans archives a deleted note's flashcards: they keep their review
+// history but are never scheduled again
+func ArchiveOrphans(store storage.Store, orphan OrphanedNote) error {
+	return setCardStatus(store, orphan.Cards, storage.StatusOrphaned, storage.StatusArchived)
+}
+
+// DeleteOrphans deletes a deleted note's orphaned flashcards, and the note
+// itself once no flashcards refer to it
+func DeleteOrphans(store storage.Store, orphan OrphanedNote) error {
+	for _, card := range orphan.Cards {
+		if err := store.DeleteFlashcard(card.ID); err != nil {
+			return fmt.Errorf("failed to delete flashcard: %w", err)
+		}
+	}
+
+	cards, err := store.GetAllFlashcards()
+	if err != nil {
+		return fmt.Errorf("failed to get flashcards: %w", err)
+	}
+	for _, card := range cards {
+		if card.NoteID == orphan.Note.ID {
+			return nil
+		}
+	}
+
+	return store.DeleteNote(orphan.Note.ID)
+}
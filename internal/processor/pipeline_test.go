@@ -0,0 +1,215 @@
+package processor_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/valdezdata/md-study/internal/generator"
+	"github.com/valdezdata/md-study/internal/processor"
+	"github.com/valdezdata/md-study/internal/scheduler"
+	"github.com/valdezdata/md-study/internal/storage"
+)
+
+// fixedClock always tells the same time
+type fixedClock struct{ t time.Time }
+
+func (c fixedClock) Now() time.Time { return c.t }
+
+var pipelineNotes = map[string]string{
+	"networking/tcp.md": `---
+title: TCP
+tags: [networking]
+---
+# TCP
+
+TCP is a connection-oriented transport protocol.
+The handshake is three steps long.
+
+## Ports
+
+HTTPS uses port 443 by default.
+`,
+	"go/channels.md": `# Channels
+
+A channel is a typed conduit for values between goroutines.
+
+What closes a channel? :: The close builtin
+`,
+}
+
+// writeNotes writes the test notes to a new directory
+func writeNotes(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range pipelineNotes {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// importAndGenerate imports the test notes into a new store and generates
+// flashcards for them with the given provider
+func importAndGenerate(t *testing.T, settings generator.Settings, record string, accept bool) *storage.MemoryStore {
+	t.Helper()
+	store := storage.NewMemoryStore()
+
+	summary, err := processor.ImportMarkdownFiles(store, writeNotes(t), processor.ImportOptions{Deck: "study"})
+	if err != nil {
+		t.Fatalf("ImportMarkdownFiles: %v", err)
+	}
+	if summary.New != 2 || summary.CardsAdded != 1 {
+		t.Fatalf("imported %d new notes and %d inline cards, want 2 and 1", summary.New, summary.CardsAdded)
+	}
+
+	generators, err := generator.NewSet(settings, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	generators.Record = record
+
+	opts := processor.GenerateOptions{Generators: generators, Workers: 2, Accept: accept}
+	if err := processor.GenerateFlashcardsForAllNotes(context.Background(), store, opts); err != nil {
+		t.Fatalf("GenerateFlashcardsForAllNotes: %v", err)
+	}
+	return store
+}
+
+// cardsBySource splits a store's flashcards into generated and inline ones
+func cardsBySource(t *testing.T, store storage.Store) (generated, inline []storage.Flashcard) {
+	t.Helper()
+	cards, err := store.GetAllFlashcards()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, card := range cards {
+		if card.Source == storage.SourceInline {
+			inline = append(inline, card)
+		} else {
+			generated = append(generated, card)
+		}
+	}
+	return generated, inline
+}
+
+func TestPipelineOffline(t *testing.T) {
+	store := importAndGenerate(t, generator.Settings{Provider: generator.ProviderOffline}, "", false)
+	schedulers := scheduler.Set{Default: scheduler.SM2{}}
+
+	generated, inline := cardsBySource(t, store)
+	if len(inline) != 1 || inline[0].Status != storage.StatusActive {
+		t.Fatalf("inline cards = %+v, want one active card", inline)
+	}
+	// Each note is short enough for one card, from its first definition
+	if len(generated) != 2 {
+		t.Fatalf("%d generated cards, want 2: %+v", len(generated), generated)
+	}
+	for _, card := range generated {
+		if card.Status != storage.StatusPending {
+			t.Errorf("generated card %q is %s, want %s", card.Question, card.Status, storage.StatusPending)
+		}
+		if card.SourceStart == 0 {
+			t.Errorf("generated card %q wasn't matched to its note", card.Question)
+		}
+	}
+
+	// Only the inline card is studied until the generated ones are accepted
+	clock := fixedClock{time.Now().Add(time.Minute)}
+	due, err := scheduler.GetDueFlashcards(store, schedulers, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) != 1 || due[0].ID != inline[0].ID {
+		t.Fatalf("due = %+v, want only the inline card", due)
+	}
+
+	newCards, err := processor.GetNewCards(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, card := range newCards {
+		if err := processor.AcceptCard(store, card.Flashcard); err != nil {
+			t.Fatal(err)
+		}
+	}
+	due, err = scheduler.GetDueFlashcards(store, schedulers, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) != 3 {
+		t.Fatalf("%d cards due after accepting, want 3", len(due))
+	}
+
+	// Studying schedules each card by its rating
+	for _, card := range due {
+		rating := scheduler.Good
+		if card.Source == storage.SourceInline {
+			rating = scheduler.Again
+		}
+		if err := scheduler.UpdateFlashcard(store, schedulers, clock, card.ID, rating, 2*time.Second); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, card := range due {
+		got, err := store.GetFlashcard(card.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.RepCount != 1 || !got.LastReview.Equal(clock.t) || !got.NextReview.Equal(clock.t.Add(24*time.Hour)) {
+			t.Errorf("card %q: %d reps, reviewed %v, next %v, want 1 rep and the next review a day after %v",
+				got.Question, got.RepCount, got.LastReview, got.NextReview, clock.t)
+		}
+	}
+	if due, _ := scheduler.GetDueFlashcards(store, schedulers, clock); len(due) != 0 {
+		t.Errorf("%d cards still due after studying", len(due))
+	}
+	if reviews, _ := store.GetAllReviews(); len(reviews) != 3 {
+		t.Errorf("%d reviews logged, want 3", len(reviews))
+	}
+}
+
+func TestPipelineReplay(t *testing.T) {
+	// Record the offline generator's responses as fixtures, then generate
+	// again from them with the replay provider
+	fixtures := t.TempDir()
+	recorded := importAndGenerate(t, generator.Settings{Provider: generator.ProviderOffline}, fixtures, false)
+	replayed := importAndGenerate(t, generator.Settings{Provider: generator.ProviderReplay, Fixtures: fixtures}, "", true)
+
+	want, _ := cardsBySource(t, recorded)
+	got, _ := cardsBySource(t, replayed)
+	questions := func(cards []storage.Flashcard) []string {
+		var qs []string
+		for _, card := range cards {
+			qs = append(qs, card.Question+" / "+card.Answer)
+		}
+		slices.Sort(qs)
+		return qs
+	}
+	if !slices.Equal(questions(got), questions(want)) {
+		t.Errorf("replayed cards %v, want the recorded %v", questions(got), questions(want))
+	}
+
+	// Accepted cards are studied straight away
+	now := time.Now().Add(time.Minute)
+	for _, card := range got {
+		if card.Status != storage.StatusActive || card.NextReview.After(now) {
+			t.Errorf("card %q is %s, due %v, want active and due now", card.Question, card.Status, card.NextReview)
+		}
+	}
+	due, err := scheduler.GetDueFlashcards(replayed, scheduler.Set{Default: scheduler.FSRS{DesiredRetention: 0.9}}, fixedClock{now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) != len(got)+1 {
+		t.Errorf("%d cards due, want the %d generated ones and the inline one", len(due), len(got))
+	}
+}
@@ -0,0 +1,79 @@
+package processor
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// progressWidth is the number of characters in a progress bar
+const progressWidth = 30
+
+// progress shows how many of a batch of notes are done. On a terminal it
+// draws a bar on the last line, redrawn in place, with messages printed above
+// it; otherwise only the messages are printed. It is safe for concurrent use.
+type progress struct {
+	mu    sync.Mutex
+	out   io.Writer
+	tty   bool
+	total int
+	done  int
+}
+
+// newProgress starts a progress display for total notes on standard output
+func newProgress(total int) *progress {
+	p := &progress{out: os.Stdout, tty: isTerminal(os.Stdout), total: total}
+	p.draw()
+	return p
+}
+
+// Logf prints a line above the bar
+func (p *progress) Logf(format string, args ...any) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.clear()
+	fmt.Fprintf(p.out, format+"\n", args...)
+	p.draw()
+}
+
+// Step marks one more note as done and returns how many are
+func (p *progress) Step() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.done++
+	p.clear()
+	p.draw()
+	return p.done
+}
+
+// Finish removes the bar
+func (p *progress) Finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.clear()
+}
+
+func (p *progress) draw() {
+	if !p.tty || p.total == 0 {
+		return
+	}
+	filled := progressWidth * p.done / p.total
+	fmt.Fprintf(p.out, "[%s%s] %d/%d notes", strings.Repeat("#", filled), strings.Repeat("-", progressWidth-filled), p.done, p.total)
+}
+
+func (p *progress) clear() {
+	if p.tty {
+		fmt.Fprint(p.out, "\r\033[K")
+	}
+}
+
+// isTerminal reports whether f is a terminal rather than a file or pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
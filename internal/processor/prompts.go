@@ -0,0 +1,166 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// promptDir is the directory in the data directory that holds prompt templates
+const promptDir = "prompts"
+
+// promptExt is the extension of prompt template files
+const promptExt = ".tmpl"
+
+// defaultPromptName is the template used for notes no tag picks a template for
+const defaultPromptName = "default"
+
+// defaultPrompt is used when the prompts directory has no default.tmpl
+const defaultPrompt = `Create {{.Count}} flashcards from the following notes.
+
+{{if .Title}}Title: {{.Title}}
+{{end}}{{if .Section}}Section: {{.Section}}
+{{end}}Notes:
+{{.Notes}}`
+
+// promptFormat is added after every prompt, so the reply can be parsed
+// whatever the template asks for
+const promptFormat = "For facts best recalled in context you may write a cloze card instead of a question, marking each hidden part as {{c1::hidden part}}. " +
+	"Reply with a JSON object {\"cards\": [...]} where each card has \"type\" (\"basic\" or \"cloze\"), \"question\", \"answer\" (empty for cloze cards), " +
+	"\"tags\" and \"source_quote\", the passage of the notes the card is based on, quoted word for word."
+
+// promptData is what a prompt template can use
+type promptData struct {
+	Title   string   // Title of the note
+	Tags    []string // Tags of the note
+	Section string   // Heading path of the text, e.g. "Networking > TCP"
+	Count   int      // Number of flashcards to ask for
+	Notes   string   // Markdown to create flashcards from
+}
+
+// promptFuncs are the functions prompt templates can call besides the built-in ones
+var promptFuncs = template.FuncMap{"join": strings.Join}
+
+// Prompts holds the prompt templates: the default, and templates picked by a
+// note's tags, read from files in the prompts directory named after the tag
+// (vocabulary.tmpl, or lang/spanish.tmpl for a nested tag)
+type Prompts struct {
+	byName map[string]*template.Template
+
+	// Override, if set, is used for every note instead, as with generate --prompt
+	Override *template.Template
+}
+
+// LoadPrompts reads the prompt templates in dataDir's prompts directory; without
+// one, every note gets the built-in prompt
+func LoadPrompts(dataDir string) (*Prompts, error) {
+	p := &Prompts{byName: make(map[string]*template.Template)}
+
+	dir := filepath.Join(dataDir, promptDir)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != promptExt {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		tmpl, err := parsePromptFile(path)
+		if err != nil {
+			return err
+		}
+		p.byName[filepath.ToSlash(strings.TrimSuffix(rel, promptExt))] = tmpl
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// SetOverride makes every note use one template: a file, or the name of a
+// template in the prompts directory
+func (p *Prompts) SetOverride(nameOrPath string) error {
+	if tmpl, ok := p.byName[strings.TrimSuffix(nameOrPath, promptExt)]; ok {
+		p.Override = tmpl
+		return nil
+	}
+
+	tmpl, err := parsePromptFile(nameOrPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("no prompt template %s: not a file, or a template in the %s directory", nameOrPath, promptDir)
+	}
+	if err != nil {
+		return err
+	}
+	p.Override = tmpl
+	return nil
+}
+
+// forTags returns the template for a note with these tags: the override if
+// set, then the template of the first tag that has one, then the default
+func (p *Prompts) forTags(tags []string) *template.Template {
+	if p == nil {
+		return builtinPrompt
+	}
+	if p.Override != nil {
+		return p.Override
+	}
+	for _, tag := range tags {
+		if tmpl, ok := p.byName[tag]; ok {
+			return tmpl
+		}
+	}
+	if tmpl, ok := p.byName[defaultPromptName]; ok {
+		return tmpl
+	}
+	return builtinPrompt
+}
+
+// builtinPrompt is the parsed defaultPrompt
+var builtinPrompt = template.Must(template.New(defaultPromptName).Funcs(promptFuncs).Parse(defaultPrompt))
+
+// parsePromptFile reads a prompt template, checking that it only uses
+// variables that exist
+func parsePromptFile(path string) (*template.Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := template.New(filepath.Base(path)).Funcs(promptFuncs).Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid prompt template: %w", err)
+	}
+	if err := tmpl.Execute(io.Discard, promptData{Tags: []string{}}); err != nil {
+		return nil, fmt.Errorf("invalid prompt template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// renderPrompt fills in a prompt template and adds any further instructions
+// that aren't empty, then the reply format
+func renderPrompt(tmpl *template.Template, data promptData, instructions ...string) (string, error) {
+	var prompt strings.Builder
+	if err := tmpl.Execute(&prompt, data); err != nil {
+		return "", fmt.Errorf("failed to fill in prompt template %s: %w", tmpl.Name(), err)
+	}
+
+	parts := []string{strings.TrimSpace(prompt.String())}
+	for _, instruction := range instructions {
+		if instruction != "" {
+			parts = append(parts, instruction)
+		}
+	}
+	return strings.Join(append(parts, promptFormat), "\n\n"), nil
+}
@@ -0,0 +1,65 @@
+package processor
+
+import (
+	"cmp"
+
+	"github.com/valdezdata/md-study/internal/storage"
+)
+
+// sameQuestionThreshold is how similar two questions must be to count as the same card
+const sameQuestionThreshold = 0.7
+
+// cardMerge is the result of matching regenerated cards against a note's existing ones
+type cardMerge struct {
+	Keep   []storage.Flashcard // Existing cards still asked, with updated text
+	Add    []storage.Flashcard // New cards with no existing counterpart
+	Remove []storage.Flashcard // Existing cards no longer generated
+}
+
+// mergeCards pairs each generated card with the most similar existing card.
+// Matched cards keep their ID, scheduling state and review history but take
+// the new question and answer text. Cloze cards only match the same index.
+func mergeCards(existing, generated []storage.Flashcard) cardMerge {
+	var merge cardMerge
+	used := make([]bool, len(existing))
+
+	for _, card := range generated {
+		best, bestScore := -1, sameQuestionThreshold
+		for i, old := range existing {
+			if used[i] || old.Type != card.Type || old.ClozeIndex != card.ClozeIndex {
+				continue
+			}
+			if score := similarity(old.Question, card.Question); score >= bestScore {
+				best, bestScore = i, score
+			}
+		}
+
+		if best < 0 {
+			merge.Add = append(merge.Add, card)
+			continue
+		}
+
+		used[best] = true
+		kept := existing[best]
+		kept.Question = card.Question
+		kept.Answer = card.Answer
+		kept.Tags = card.Tags
+		kept.Section = card.Section
+		kept.SourceQuote = card.SourceQuote
+		kept.SourceStart, kept.SourceEnd = card.SourceStart, card.SourceEnd
+
+		// The answer may have changed, so whether it is verified comes from the new card
+		if kept.Status == storage.StatusActive || kept.Status == storage.StatusUnverified {
+			kept.Status = cmp.Or(card.Status, storage.StatusActive)
+		}
+		merge.Keep = append(merge.Keep, kept)
+	}
+
+	for i, old := range existing {
+		if !used[i] {
+			merge.Remove = append(merge.Remove, old)
+		}
+	}
+
+	return merge
+}
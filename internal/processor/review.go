@@ -0,0 +1,298 @@
+package processor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/valdezdata/md-study/internal/cloze"
+	"github.com/valdezdata/md-study/internal/generator"
+	"github.com/valdezdata/md-study/internal/storage"
+)
+
+// rejectedFile is the log of rejected flashcards in the data directory
+const rejectedFile = "rejected.jsonl"
+
+// maxRejectedExamples is how many rejected flashcards a prompt shows the model
+const maxRejectedExamples = 10
+
+// RejectedCard is a generated flashcard that was rejected in review, kept so
+// later prompts can tell the model what to avoid
+type RejectedCard struct {
+	Time     time.Time `json:"time"`
+	Deck     string    `json:"deck,omitempty"`
+	Type     string    `json:"type"`
+	Question string    `json:"question"`
+	Answer   string    `json:"answer,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+}
+
+// LoadRejected reads the flashcards rejected so far from the data directory,
+// oldest first
+func LoadRejected(dataDir string) ([]RejectedCard, error) {
+	f, err := os.Open(filepath.Join(dataDir, rejectedFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open rejected flashcards: %w", err)
+	}
+	defer f.Close()
+
+	var rejected []RejectedCard
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var r RejectedCard
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			// A line cut short by a crash is skipped rather than losing the rest
+			continue
+		}
+		rejected = append(rejected, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read rejected flashcards: %w", err)
+	}
+	return rejected, nil
+}
+
+// recordRejected appends a rejected flashcard to the log in the data directory
+func recordRejected(dataDir string, r RejectedCard) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to marshal rejected flashcard: %w", err)
+	}
+
+	f, err := os.OpenFile(filepath.Join(dataDir, rejectedFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open rejected flashcards: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to record rejected flashcard: %w", err)
+	}
+	return nil
+}
+
+// rejectedExamples tells the model which flashcards were rejected before in
+// a deck, the most recent first, or returns nothing if none were
+func rejectedExamples(rejected []RejectedCard, deck string) string {
+	var examples []string
+	for i := len(rejected) - 1; i >= 0 && len(examples) < maxRejectedExamples; i-- {
+		r := rejected[i]
+		if r.Deck != deck {
+			continue
+		}
+		example := "- " + oneLine(r.Question)
+		if r.Answer != "" && r.Type != storage.TypeCloze {
+			example += " / " + oneLine(r.Answer)
+		}
+		if r.Reason != "" {
+			example += " (rejected because: " + oneLine(r.Reason) + ")"
+		}
+		examples = append(examples, example)
+	}
+
+	if len(examples) == 0 {
+		return ""
+	}
+	return "These flashcards were written for similar notes before and rejected. Don't write flashcards like them:\n" +
+		strings.Join(examples, "\n")
+}
+
+// NewCard is a generated flashcard held out of study until it is reviewed,
+// because it is pending or unverified, with its note
+type NewCard struct {
+	storage.Flashcard
+	Note storage.Note
+}
+
+// getCardsWithStatus returns the flashcards with any of the statuses, with their notes
+func getCardsWithStatus(store storage.Store, statuses ...string) ([]NewCard, error) {
+	notes, err := store.GetAllNotes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notes: %w", err)
+	}
+	cards, err := store.GetAllFlashcards()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get flashcards: %w", err)
+	}
+
+	notesByID := make(map[string]storage.Note)
+	for _, note := range notes {
+		notesByID[note.ID] = note
+	}
+
+	var found []NewCard
+	for _, card := range cards {
+		if slices.Contains(statuses, card.Status) {
+			found = append(found, NewCard{Flashcard: card, Note: notesByID[card.NoteID]})
+		}
+	}
+	return found, nil
+}
+
+// GetNewCards returns every generated flashcard waiting for review: pending
+// cards and unverified ones
+func GetNewCards(store storage.Store) ([]NewCard, error) {
+	return getCardsWithStatus(store, storage.StatusPending, storage.StatusUnverified)
+}
+
+// SourceLines returns the lines of the note a card's passage spans, or
+// nothing if no passage was found
+func (c NewCard) SourceLines() string {
+	if c.SourceStart == 0 {
+		return ""
+	}
+	lines := strings.Split(c.Note.RawContent, "\n")
+	if c.SourceEnd > len(lines) || c.SourceStart > c.SourceEnd {
+		return ""
+	}
+	return strings.Join(lines[c.SourceStart-1:c.SourceEnd], "\n")
+}
+
+// AcceptCard puts a flashcard held out of study into study, due now
+func AcceptCard(store storage.Store, card storage.Flashcard) error {
+	card.Status = storage.StatusActive
+	card.NextReview = time.Now()
+	if err := store.UpdateFlashcard(card); err != nil {
+		return fmt.Errorf("failed to update flashcard: %w", err)
+	}
+	return nil
+}
+
+// EditCard changes a flashcard's question and answer, keeping whichever is
+// empty. A cloze card's question is its text, and its answer follows from it.
+func EditCard(store storage.Store, card storage.Flashcard, question, answer string) (storage.Flashcard, error) {
+	if question = strings.TrimSpace(question); question != "" {
+		card.Question = question
+	}
+	if card.Type == storage.TypeCloze {
+		if !slices.Contains(cloze.Indexes(card.Question), card.ClozeIndex) {
+			return card, fmt.Errorf("the text has no {{c%d::...}} deletion", card.ClozeIndex)
+		}
+		card.Answer = cloze.Answer(card.Question, card.ClozeIndex)
+	} else if answer = strings.TrimSpace(answer); answer != "" {
+		card.Answer = answer
+	}
+
+	if err := store.UpdateFlashcard(card); err != nil {
+		return card, fmt.Errorf("failed to update flashcard: %w", err)
+	}
+	return card, nil
+}
+
+// RejectCard deletes a new flashcard and records it, with the reason if one
+// is given, so later prompts for its deck ask the model to avoid cards like it
+func RejectCard(store storage.Store, dataDir string, card NewCard, reason string) error {
+	if err := store.DeleteFlashcard(card.ID); err != nil {
+		return fmt.Errorf("failed to delete flashcard: %w", err)
+	}
+	return recordRejected(dataDir, RejectedCard{
+		Time:     time.Now(),
+		Deck:     card.Note.Deck,
+		Type:     card.Type,
+		Question: card.Question,
+		Answer:   card.Answer,
+		Reason:   strings.TrimSpace(reason),
+	})
+}
+
+// RegenerateCard asks the model for a flashcard to replace a new one, from
+// the chunk of the note the card came from, and saves it in the card's place.
+// The replacement is pending, or unverified if its answer can't be found in
+// the note.
+func RegenerateCard(ctx context.Context, gen generator.Generator, store storage.Store, card NewCard, opts GenerateOptions) (NewCard, error) {
+	note := card.Note
+	c := cardChunk(note, card.Flashcard)
+
+	replace := "Write exactly one flashcard, to replace this one, which was turned down: " + card.Question
+	if card.Type != storage.TypeCloze {
+		replace += " / " + card.Answer
+	}
+	prompt, err := renderPrompt(opts.Prompts.forTags(note.Tags), promptData{
+		Title:   note.Title,
+		Tags:    note.Tags,
+		Section: strings.Join(c.Path(), " > "),
+		Count:   1,
+		Notes:   c.Content,
+	}, replace, rejectedExamples(opts.Rejected, note.Deck))
+	if err != nil {
+		return NewCard{}, err
+	}
+
+	req := generator.Request{
+		System:   systemPrompt,
+		Prompt:   prompt,
+		Schema:   &generator.CardSchema,
+		Source:   c.Content,
+		Section:  c.Path(),
+		Count:    1,
+		NoteHash: note.ContentHash,
+		Validate: validCardReply,
+	}
+	logf := func(format string, args ...any) { fmt.Printf(format+"\n", args...) }
+
+	cards, err := generateChunkFlashcards(ctx, gen, note, req, logf)
+	if err != nil {
+		return NewCard{}, err
+	}
+	if len(cards) == 0 {
+		return NewCard{}, fmt.Errorf("the model wrote no flashcard")
+	}
+	cards = cards[:1]
+	groundCards(ctx, gen, note, c.Content, cards, opts.Verify, logf)
+
+	replacement := cards[0]
+	replacement.ID = uuid.New().String()
+	replacement.Tags = uniqueTags(append(slices.Clone(note.Tags), replacement.Tags...))
+	replacement.Section = c.sectionFor(replacement)
+	replacement.Source = storage.SourceGenerated
+	if replacement.Status == "" {
+		replacement.Status = storage.StatusPending
+	}
+
+	if err := store.DeleteFlashcard(card.ID); err != nil {
+		return NewCard{}, fmt.Errorf("failed to delete flashcard: %w", err)
+	}
+	if err := store.SaveFlashcard(replacement); err != nil {
+		return NewCard{}, fmt.Errorf("failed to save flashcard: %w", err)
+	}
+	return NewCard{Flashcard: replacement, Note: note}, nil
+}
+
+// cardChunk returns the chunk of a note a flashcard was generated from: the
+// one its source quote is in, or else the one with its section
+func cardChunk(note storage.Note, card storage.Flashcard) chunk {
+	chunks := noteChunks(note)
+	if len(chunks) == 0 {
+		return chunk{Content: note.RawContent}
+	}
+
+	if quote := normalizeSpace(card.SourceQuote); quote != "" {
+		for _, c := range chunks {
+			if strings.Contains(normalizeSpace(c.Content), quote) {
+				return c
+			}
+		}
+	}
+	for _, c := range chunks {
+		if path := c.Path(); len(path) > 0 && len(card.Section) >= len(path) && slices.Equal(card.Section[:len(path)], path) {
+			return c
+		}
+	}
+	return chunks[0]
+}
@@ -0,0 +1,284 @@
+package processor
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// blockKind is the type of a top-level markdown block
+type blockKind string
+
+const (
+	blockParagraph blockKind = "paragraph"
+	blockCode      blockKind = "code"
+	blockList      blockKind = "list"
+	blockTable     blockKind = "table"
+	blockQuote     blockKind = "quote"
+)
+
+// block is a run of lines that belongs together and must not be split, such
+// as a whole fenced code block, list or table
+type block struct {
+	Kind      blockKind
+	StartLine int    // First line, counting from 1 in the whole file
+	EndLine   int    // Last line
+	Language  string // Info string of a fenced code block
+}
+
+// section is the part of a note below one heading, up to the next heading
+type section struct {
+	Path      []string // Headings from the top level down to this section's own
+	Level     int      // Heading level, 0 for text before the first heading
+	StartLine int      // Line of the heading, counting from 1 in the whole file
+	EndLine   int      // Last line before the next heading
+	Content   string   // Markdown of the section, including its heading
+	Blocks    []block
+}
+
+// HasContent reports whether the section holds anything besides its heading
+func (s section) HasContent() bool {
+	return len(s.Blocks) > 0
+}
+
+// PathString joins the heading path for display, e.g. "Networking > TCP > Handshake"
+func (s section) PathString() string {
+	return strings.Join(s.Path, " > ")
+}
+
+var (
+	atxHeading      = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	setextUnderline = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	fenceOpen       = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})[ \t]*([^`]*)$")
+	listItem        = regexp.MustCompile(`^ {0,3}(?:[-*+]|\d{1,9}[.)])(?:[ \t]|$)`)
+	tableDelimiter  = regexp.MustCompile(`^ {0,3}\|?[ \t]*:?-+:?[ \t]*(?:\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+	thematicBreak   = regexp.MustCompile(`^ {0,3}(?:(?:-[ \t]*){3,}|(?:\*[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+)
+
+// parseSections splits a note into sections by its ATX ("## Title") and
+// setext (underlined) headings. Front matter is skipped but still counted in
+// line numbers, and headings inside fenced code blocks are ignored. A note
+// without headings is a single section with an empty path.
+func parseSections(rawContent string) []section {
+	content := []byte(rawContent)
+	_, body, _ := splitFrontMatter(content)
+	offset := bytes.Count(content[:len(content)-len(body)], []byte("\n"))
+
+	lines := strings.Split(strings.TrimSuffix(string(body), "\n"), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSuffix(lines[i], "\r")
+	}
+
+	p := &sectionParser{lines: lines, offset: offset}
+	p.parse()
+	return p.sections
+}
+
+// sectionParser walks the lines of a note body once, block by block
+type sectionParser struct {
+	lines    []string
+	offset   int // Lines of front matter before the body
+	sections []section
+	headings []heading // Open headings, outermost first
+}
+
+// heading is an entry in the current heading path
+type heading struct {
+	level int
+	text  string
+}
+
+// lineNo converts an index into lines to a line number in the file
+func (p *sectionParser) lineNo(i int) int {
+	return i + 1 + p.offset
+}
+
+func (p *sectionParser) parse() {
+	p.sections = []section{{StartLine: p.lineNo(0)}}
+
+	for i := 0; i < len(p.lines); {
+		line := p.lines[i]
+
+		if strings.TrimSpace(line) == "" {
+			i++
+			continue
+		}
+
+		if m := atxHeading.FindStringSubmatch(line); m != nil {
+			p.startSection(i, len(m[1]), m[2])
+			i++
+			continue
+		}
+
+		if m := fenceOpen.FindStringSubmatch(line); m != nil {
+			i = p.fencedCode(i, m[1], strings.TrimSpace(m[2]))
+			continue
+		}
+
+		if listItem.MatchString(line) && !thematicBreak.MatchString(line) {
+			i = p.list(i)
+			continue
+		}
+
+		if strings.HasPrefix(strings.TrimSpace(line), ">") {
+			i = p.run(i, blockQuote, func(l string) bool {
+				return strings.HasPrefix(strings.TrimSpace(l), ">")
+			})
+			continue
+		}
+
+		if strings.Contains(line, "|") && i+1 < len(p.lines) && tableDelimiter.MatchString(p.lines[i+1]) &&
+			strings.Contains(p.lines[i+1], "-") {
+			i = p.run(i, blockTable, func(l string) bool {
+				return strings.Contains(l, "|")
+			})
+			continue
+		}
+
+		if thematicBreak.MatchString(line) {
+			i++
+			continue
+		}
+
+		i = p.paragraph(i)
+	}
+
+	p.finish(len(p.lines))
+}
+
+// startSection closes the current section and opens one for a heading at line i
+func (p *sectionParser) startSection(i, level int, text string) {
+	p.finish(i)
+
+	for len(p.headings) > 0 && p.headings[len(p.headings)-1].level >= level {
+		p.headings = p.headings[:len(p.headings)-1]
+	}
+	p.headings = append(p.headings, heading{level: level, text: strings.TrimSpace(text)})
+
+	path := make([]string, len(p.headings))
+	for j, h := range p.headings {
+		path[j] = h.text
+	}
+
+	p.sections = append(p.sections, section{Path: path, Level: level, StartLine: p.lineNo(i)})
+}
+
+// finish ends the current section just before line index end, dropping an
+// empty section before the first heading
+func (p *sectionParser) finish(end int) {
+	current := &p.sections[len(p.sections)-1]
+	start := current.StartLine - p.offset - 1
+
+	// Trailing blank lines belong to no section
+	for end > start && strings.TrimSpace(p.lines[end-1]) == "" {
+		end--
+	}
+
+	if current.Level == 0 && len(current.Blocks) == 0 {
+		p.sections = p.sections[:len(p.sections)-1]
+		return
+	}
+
+	current.EndLine = p.lineNo(end - 1)
+	current.Content = strings.Join(p.lines[start:end], "\n")
+}
+
+// addBlock records a block spanning line indexes start to end, inclusive
+func (p *sectionParser) addBlock(kind blockKind, start, end int, language string) {
+	current := &p.sections[len(p.sections)-1]
+	current.Blocks = append(current.Blocks, block{
+		Kind:      kind,
+		StartLine: p.lineNo(start),
+		EndLine:   p.lineNo(end),
+		Language:  language,
+	})
+}
+
+// fencedCode consumes a fenced code block, returning the index after it; an
+// unclosed fence runs to the end of the note
+func (p *sectionParser) fencedCode(i int, fence, info string) int {
+	start := i
+	language, _, _ := strings.Cut(info, " ")
+
+	for i++; i < len(p.lines); i++ {
+		trimmed := strings.TrimSpace(p.lines[i])
+		if strings.HasPrefix(trimmed, fence[:1]) && strings.Trim(trimmed, fence[:1]) == "" && len(trimmed) >= len(fence) {
+			p.addBlock(blockCode, start, i, language)
+			return i + 1
+		}
+	}
+
+	p.addBlock(blockCode, start, len(p.lines)-1, language)
+	return len(p.lines)
+}
+
+// list consumes a list, including indented continuation lines and nested
+// lists, and blank lines between items
+func (p *sectionParser) list(i int) int {
+	start, end := i, i
+	for i++; i < len(p.lines); i++ {
+		line := p.lines[i]
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		item := listItem.MatchString(line) && !thematicBreak.MatchString(line)
+		indented := strings.HasPrefix(line, "  ") || strings.HasPrefix(line, "\t")
+		// Unindented text straight after an item lazily continues it
+		lazy := end == i-1 && !atxHeading.MatchString(line) && !fenceOpen.MatchString(line) && !thematicBreak.MatchString(line)
+		if !item && !indented && !lazy {
+			break
+		}
+		end = i
+	}
+
+	p.addBlock(blockList, start, end, "")
+	return end + 1
+}
+
+// run consumes consecutive lines matching a predicate as one block
+func (p *sectionParser) run(i int, kind blockKind, matches func(string) bool) int {
+	start := i
+	i++
+	for i < len(p.lines) && matches(p.lines[i]) {
+		i++
+	}
+	p.addBlock(kind, start, i-1, "")
+	return i
+}
+
+// paragraph consumes a paragraph, which may turn out to be a setext heading
+func (p *sectionParser) paragraph(i int) int {
+	start := i
+	for i++; i < len(p.lines); i++ {
+		line := p.lines[i]
+
+		// An underline turns the paragraph into a heading, even "---" which
+		// would otherwise be a thematic break
+		if m := setextUnderline.FindStringSubmatch(line); m != nil {
+			level := 1
+			if m[1][0] == '-' {
+				level = 2
+			}
+			p.startSection(start, level, strings.Join(trimAll(p.lines[start:i]), " "))
+			return i + 1
+		}
+
+		if strings.TrimSpace(line) == "" || atxHeading.MatchString(line) || fenceOpen.MatchString(line) ||
+			thematicBreak.MatchString(line) || listItem.MatchString(line) || strings.HasPrefix(strings.TrimSpace(line), ">") {
+			break
+		}
+	}
+
+	p.addBlock(blockParagraph, start, i-1, "")
+	return i
+}
+
+// trimAll trims the whitespace around each string
+func trimAll(lines []string) []string {
+	trimmed := make([]string, len(lines))
+	for i, line := range lines {
+		trimmed[i] = strings.TrimSpace(line)
+	}
+	return trimmed
+}
@@ -0,0 +1,51 @@
+package processor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"unicode"
+)
+
+// contentHash returns a stable fingerprint of a note's content
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeText lowercases text and reduces it to words separated by single spaces
+func normalizeText(s string) string {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	return strings.Join(fields, " ")
+}
+
+// wordSet returns the distinct words of a text after normalization
+func wordSet(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.Fields(normalizeText(s)) {
+		set[word] = true
+	}
+	return set
+}
+
+// similarity is the Jaccard similarity of the word sets of two texts, from 0 to 1
+func similarity(a, b string) float64 {
+	return jaccard(wordSet(a), wordSet(b))
+}
+
+// jaccard is the Jaccard similarity of two word sets, from 0 to 1
+func jaccard(wa, wb map[string]bool) float64 {
+	if len(wa) == 0 && len(wb) == 0 {
+		return 1
+	}
+
+	shared := 0
+	for word := range wa {
+		if wb[word] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(wa)+len(wb)-shared)
+}
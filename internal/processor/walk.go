@@ -0,0 +1,275 @@
+package processor
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const ignoreFile = ".mdstudyignore"
+
+// ignoreRule is one pattern from an exclude flag or a .mdstudyignore file
+type ignoreRule struct {
+	base    string // Directory the pattern is relative to, slash-separated ("" for the root)
+	pattern string
+	negate  bool // Pattern started with "!" and re-includes matching paths
+	dirOnly bool // Pattern ended with "/" and only matches directories
+}
+
+// parseIgnoreRule turns a gitignore-style line into a rule, reporting false for blanks and comments
+func parseIgnoreRule(base, line string) (ignoreRule, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	rule := ignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	rule.pattern = line
+
+	return rule, line != ""
+}
+
+// readIgnoreFile loads the rules from a .mdstudyignore file, if there is one
+func readIgnoreFile(dir, base string) ([]ignoreRule, error) {
+	f, err := os.Open(filepath.Join(dir, ignoreFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", ignoreFile, err)
+	}
+	defer f.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreRule(base, scanner.Text()); ok {
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules, scanner.Err()
+}
+
+// ignored reports whether rel (slash-separated, relative to the import root)
+// is excluded by the rules; later rules override earlier ones
+func ignored(rules []ignoreRule, rel string, isDir bool) bool {
+	result := false
+	for _, rule := range rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+
+		p := rel
+		if rule.base != "" {
+			if !strings.HasPrefix(rel, rule.base+"/") {
+				continue
+			}
+			p = strings.TrimPrefix(rel, rule.base+"/")
+		}
+
+		if matchPattern(rule.pattern, p) {
+			result = !rule.negate
+		}
+	}
+	return result
+}
+
+// matchPattern matches a slash-separated path against a glob. Patterns without
+// a slash match the last path element at any depth; "**" matches any number of
+// directories.
+func matchPattern(pattern, rel string) bool {
+	pattern = strings.TrimPrefix(pattern, "/")
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(rel))
+		return ok
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+}
+
+// matchSegments matches path segments against pattern segments, expanding "**"
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+// markdownFile is a file found while walking an import directory
+type markdownFile struct {
+	path string // Path under the import root, following symlinks as named
+	rel  string // Slash-separated path relative to the import root
+}
+
+// symlink is a link found while walking, visited after the rest of the tree
+type symlink struct {
+	path  string
+	rel   string
+	rules []ignoreRule
+}
+
+// walker finds markdown files below a root directory
+type walker struct {
+	include []string
+	visited map[string]bool // Real paths of directories and files already seen
+	links   []symlink       // Symlinks waiting to be followed
+	files   []markdownFile
+	skipped []string // Reasons files were skipped, for the import summary
+}
+
+// walk collects markdown files under root, applying include patterns, ignore
+// rules and any .mdstudyignore files. Symlinks are followed once the rest of
+// the tree has been walked, so files are recorded under their real location
+// when it is inside the root, and each real directory and file is visited only
+// once so link cycles can't loop forever.
+func (w *walker) walk(root string, rules []ignoreRule) error {
+	real, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return fmt.Errorf("failed to read directory: %w", err)
+	}
+	w.visited[real] = true
+
+	if err := w.walkDir(root, "", rules); err != nil {
+		return err
+	}
+
+	// Following a link can find more links, so this list may grow as we go
+	for i := 0; i < len(w.links); i++ {
+		link := w.links[i]
+		if err := w.visit(link.path, link.rel, link.rules, true); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// walkDir processes one directory; rel is its slash-separated path from the root
+func (w *walker) walkDir(dir, rel string, rules []ignoreRule) error {
+	local, err := readIgnoreFile(dir, rel)
+	if err != nil {
+		return err
+	}
+	rules = append(rules[:len(rules):len(rules)], local...)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		fullPath := filepath.Join(dir, entry.Name())
+		entryRel := path.Join(rel, entry.Name())
+
+		if entry.Type()&os.ModeSymlink != 0 {
+			w.links = append(w.links, symlink{path: fullPath, rel: entryRel, rules: rules})
+			continue
+		}
+
+		if err := w.visit(fullPath, entryRel, rules, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// visit handles a single directory entry, descending into directories
+func (w *walker) visit(fullPath, rel string, rules []ignoreRule, isLink bool) error {
+	name := path.Base(rel)
+
+	info, err := os.Stat(fullPath) // follows symlinks
+	if err != nil {
+		w.skip(rel, "broken symlink")
+		return nil
+	}
+
+	if info.IsDir() {
+		// Hidden directories such as .git and .obsidian never hold notes
+		if strings.HasPrefix(name, ".") || ignored(rules, rel, true) {
+			return nil
+		}
+
+		real, err := filepath.EvalSymlinks(fullPath)
+		if err != nil {
+			w.skip(rel, "unresolvable symlink")
+			return nil
+		}
+		if w.visited[real] {
+			if isLink {
+				w.skip(rel, "symlink to a directory already imported")
+			}
+			return nil
+		}
+		w.visited[real] = true
+
+		return w.walkDir(fullPath, rel, rules)
+	}
+
+	if !info.Mode().IsRegular() || !strings.HasSuffix(strings.ToLower(name), ".md") {
+		return nil
+	}
+
+	if ignored(rules, rel, false) || !w.included(rel) {
+		w.skip(rel, "excluded")
+		return nil
+	}
+
+	real, err := filepath.EvalSymlinks(fullPath)
+	if err != nil {
+		w.skip(rel, "unresolvable symlink")
+		return nil
+	}
+	if w.visited[real] {
+		w.skip(rel, "same file already imported through another path")
+		return nil
+	}
+	w.visited[real] = true
+
+	w.files = append(w.files, markdownFile{path: fullPath, rel: rel})
+	return nil
+}
+
+// included reports whether a file matches the include patterns; with none, every file does
+func (w *walker) included(rel string) bool {
+	if len(w.include) == 0 {
+		return true
+	}
+	for _, pattern := range w.include {
+		if matchPattern(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// skip records a file that won't be imported
+func (w *walker) skip(rel, reason string) {
+	w.skipped = append(w.skipped, fmt.Sprintf("%s (%s)", rel, reason))
+}
@@ -0,0 +1,171 @@
+package scheduler
+
+import (
+	"math"
+	"time"
+
+	"github.com/valdezdata/md-study/internal/storage"
+)
+
+// Default FSRS-4.5 model weights
+var fsrsWeights = [17]float64{
+	0.4872, 1.4003, 3.7145, 13.8206, 5.1618, 1.2298, 0.8975, 0.031,
+	1.6474, 0.1367, 1.0461, 2.1072, 0.0793, 0.3246, 1.587, 0.2272, 2.8755,
+}
+
+// Shape of the FSRS forgetting curve; factor makes R(S, S) equal 90%
+const (
+	fsrsDecay  = -0.5
+	fsrsFactor = 19.0 / 81.0
+
+	fsrsMaxInterval = 36500
+)
+
+// fsrsGrade maps a rating to the 1-4 grade used by FSRS
+var fsrsGrade = map[int]float64{
+	Again: 1,
+	Hard:  2,
+	Good:  3,
+	Easy:  4,
+}
+
+// retrievability is the probability of recalling a card with the given
+// stability after elapsed days
+func retrievability(elapsed, stability float64) float64 {
+	if stability <= 0 {
+		return 0
+	}
+	return math.Pow(1+fsrsFactor*elapsed/stability, fsrsDecay)
+}
+
+// fsrsInterval returns the number of days until recall probability falls to retention
+func fsrsInterval(stability, retention float64) float64 {
+	days := stability / fsrsFactor * (math.Pow(retention, 1/fsrsDecay) - 1)
+	return math.Min(math.Max(math.Round(days), 1), fsrsMaxInterval)
+}
+
+func fsrsInitStability(g float64) float64 {
+	return math.Max(fsrsWeights[int(g)-1], 0.1)
+}
+
+func fsrsInitDifficulty(g float64) float64 {
+	return clamp(fsrsWeights[4]-(g-3)*fsrsWeights[5], 1, 10)
+}
+
+// fsrsNextDifficulty moves difficulty by the grade, with mean reversion towards
+// the initial difficulty of a "Good" card
+func fsrsNextDifficulty(d, g float64) float64 {
+	next := d - fsrsWeights[6]*(g-3)
+	return clamp(fsrsWeights[7]*fsrsInitDifficulty(3)+(1-fsrsWeights[7])*next, 1, 10)
+}
+
+// fsrsRecallStability is the new stability after a successful review
+func fsrsRecallStability(d, s, r, g float64) float64 {
+	hardPenalty, easyBonus := 1.0, 1.0
+	if g == 2 {
+		hardPenalty = fsrsWeights[15]
+	}
+	if g == 4 {
+		easyBonus = fsrsWeights[16]
+	}
+	return s * (1 + math.Exp(fsrsWeights[8])*(11-d)*math.Pow(s, -fsrsWeights[9])*
+		(math.Exp((1-r)*fsrsWeights[10])-1)*hardPenalty*easyBonus)
+}
+
+// fsrsForgetStability is the new stability after a lapse
+func fsrsForgetStability(d, s, r float64) float64 {
+	return fsrsWeights[11] * math.Pow(d, -fsrsWeights[12]) *
+		(math.Pow(s+1, fsrsWeights[13]) - 1) * math.Exp((1-r)*fsrsWeights[14])
+}
+
+// FSRS schedules cards with the Free Spaced Repetition Scheduler memory model:
+// each card has a stability and a difficulty, and the next review is set for
+// when the probability of recalling it falls to DesiredRetention
+type FSRS struct {
+	DesiredRetention float64
+}
+
+// Schedule applies one FSRS review
+func (f FSRS) Schedule(card storage.Flashcard, rating int, clock Clock) storage.Flashcard {
+	now := clock.Now()
+	return finishReview(f.step(card, rating, now), rating, now)
+}
+
+// newCardPriority orders new cards after every due review, whose priority is
+// a probability of recall
+const newCardPriority = 2
+
+// Priority is the card's current probability of recall, so the reviews most
+// likely to have been forgotten come first and new cards come last. A card
+// reviewed with another algorithm has no stability yet; its interval stands in
+// for it, since both are the days until recall falls to about 90%.
+func (f FSRS) Priority(card storage.Flashcard, now time.Time) float64 {
+	if card.RepCount == 0 {
+		return newCardPriority
+	}
+	stability := card.Stability
+	if stability == 0 {
+		stability = math.Max(card.Interval, 1)
+	}
+	return retrievability(now.Sub(card.LastReview).Hours()/24, stability)
+}
+
+// NeedsReplay reports whether a card has been reviewed but has no FSRS state yet
+func (f FSRS) NeedsReplay(card storage.Flashcard) bool {
+	return card.Stability == 0 && card.RepCount > 0
+}
+
+// Replay rebuilds a card's FSRS memory state from its review history,
+// so cards first scheduled with SM-2 don't start from scratch
+func (f FSRS) Replay(card storage.Flashcard, reviews []storage.Review) storage.Flashcard {
+	card.Stability = 0
+	card.FSRSDifficulty = 0
+	card.Repetitions = 0
+	card.Lapses = 0
+
+	for _, review := range reviews {
+		if _, ok := fsrsGrade[review.Rating]; !ok {
+			continue
+		}
+		card = f.step(card, review.Rating, review.ReviewedAt)
+		card.LastReview = review.ReviewedAt
+	}
+
+	return card
+}
+
+// step updates the memory state for a review at time now and sets the interval
+func (f FSRS) step(card storage.Flashcard, rating int, now time.Time) storage.Flashcard {
+	g := fsrsGrade[rating]
+
+	if card.Stability == 0 {
+		card.Stability = fsrsInitStability(g)
+		card.FSRSDifficulty = fsrsInitDifficulty(g)
+	} else {
+		elapsed := math.Max(now.Sub(card.LastReview).Hours()/24, 0)
+		r := retrievability(elapsed, card.Stability)
+		d := card.FSRSDifficulty
+
+		if g == 1 {
+			card.Stability = fsrsForgetStability(d, card.Stability, r)
+		} else {
+			card.Stability = fsrsRecallStability(d, card.Stability, r, g)
+		}
+		card.FSRSDifficulty = fsrsNextDifficulty(d, g)
+	}
+
+	if g == 1 {
+		card.Repetitions = 0
+		card.Lapses++
+	} else {
+		card.Repetitions++
+	}
+	card.Interval = fsrsInterval(card.Stability, f.DesiredRetention)
+
+	return card
+}
+
+// clamp limits v to the range [lo, hi]
+func clamp(v, lo, hi float64) float64 {
+	return math.Min(math.Max(v, lo), hi)
+}
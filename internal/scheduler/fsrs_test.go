@@ -0,0 +1,137 @@
+package scheduler
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/valdezdata/md-study/internal/storage"
+)
+
+// near reports whether two floats agree to within the rounding of the
+// hand-computed values below
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-4
+}
+
+func TestFSRSScheduleNewCard(t *testing.T) {
+	tests := []struct {
+		rating int
+
+		stability   float64
+		difficulty  float64
+		interval    float64
+		repetitions int
+		lapses      int
+	}{
+		// A new card's stability is its grade's weight, and its difficulty w4 - (g-3)*w5
+		{Again, 0.4872, 7.6214, 1, 0, 1},
+		{Hard, 1.4003, 6.3916, 1, 1, 0},
+		{Good, 3.7145, 5.1618, 4, 1, 0},
+		{Easy, 13.8206, 3.9320, 14, 1, 0},
+	}
+
+	f := FSRS{DesiredRetention: 0.9}
+	for _, tt := range tests {
+		got := f.Schedule(storage.Flashcard{}, tt.rating, fixedClock{testNow})
+
+		if !near(got.Stability, tt.stability) || !near(got.FSRSDifficulty, tt.difficulty) {
+			t.Errorf("rating %d: stability %v, difficulty %v, want %v and %v",
+				tt.rating, got.Stability, got.FSRSDifficulty, tt.stability, tt.difficulty)
+		}
+		// At 90% retention the interval is the stability, rounded
+		if got.Interval != tt.interval || !got.NextReview.Equal(days(tt.interval)) {
+			t.Errorf("rating %d: interval %v, next %v, want %v", tt.rating, got.Interval, got.NextReview, tt.interval)
+		}
+		if got.Repetitions != tt.repetitions || got.Lapses != tt.lapses {
+			t.Errorf("rating %d: repetitions %d, lapses %d, want %d and %d",
+				tt.rating, got.Repetitions, got.Lapses, tt.repetitions, tt.lapses)
+		}
+	}
+}
+
+func TestFSRSScheduleGrowth(t *testing.T) {
+	tests := []struct {
+		retention float64
+		intervals []float64
+	}{
+		{0.9, []float64{4, 15}},
+		{0.85, []float64{6, 24}},
+	}
+
+	for _, tt := range tests {
+		f := FSRS{DesiredRetention: tt.retention}
+
+		// Review a new card as Good, then again as Good four days later
+		card := f.Schedule(storage.Flashcard{}, Good, fixedClock{testNow})
+		first := card.Interval
+		card = f.Schedule(card, Good, fixedClock{days(4)})
+
+		if got := []float64{first, card.Interval}; !slices.Equal(got, tt.intervals) {
+			t.Errorf("retention %v: intervals %v, want %v", tt.retention, got, tt.intervals)
+		}
+		if !near(card.Stability, 14.8081) || !near(card.FSRSDifficulty, 5.1618) || card.Repetitions != 2 {
+			t.Errorf("retention %v: stability %v, difficulty %v, repetitions %d, want 14.8081, 5.1618 and 2",
+				tt.retention, card.Stability, card.FSRSDifficulty, card.Repetitions)
+		}
+	}
+}
+
+func TestFSRSReplay(t *testing.T) {
+	f := FSRS{DesiredRetention: 0.9}
+
+	// A card studied with SM-2: it has a history but no FSRS state
+	card := storage.Flashcard{ID: "c1", RepCount: 3, EaseFactor: 2.36, Interval: 1, Lapses: 1, LastReview: days(14)}
+	if !f.NeedsReplay(card) {
+		t.Fatal("NeedsReplay = false for a reviewed card without FSRS state")
+	}
+
+	reviews := []storage.Review{
+		{CardID: "c1", Rating: Good, ReviewedAt: testNow},
+		{CardID: "c1", Rating: Good, ReviewedAt: days(4)},
+		{CardID: "c1", Rating: Again, ReviewedAt: days(14)},
+	}
+	got := f.Replay(card, reviews)
+
+	// Good: S = w2 = 3.7145 and D = w4 = 5.1618.
+	// Good after 4 days: R = (1 + 19/81 * 4/3.7145)^-0.5 = 0.893500, so
+	// S = 3.7145 * (1 + e^w8 * (11-D) * 3.7145^-w9 * (e^((1-R)*w10) - 1)) = 14.8081,
+	// and D reverts to the initial difficulty of Good, where it already is.
+	// Again after 10 more days: R = 0.929116, so
+	// S = w11 * D^-w12 * ((S+1)^w13 - 1) * e^((1-R)*w14) = 3.0018, and
+	// D = w7*w4 + (1-w7) * (D + 2*w6) = 6.9012.
+	if !near(got.Stability, 3.0018) || !near(got.FSRSDifficulty, 6.9012) {
+		t.Errorf("stability %v, difficulty %v, want 3.0018 and 6.9012", got.Stability, got.FSRSDifficulty)
+	}
+	if got.Interval != 3 || got.Repetitions != 0 || got.Lapses != 1 || !got.LastReview.Equal(days(14)) {
+		t.Errorf("interval %v, repetitions %d, lapses %d, last review %v, want 3, 0, 1 and %v",
+			got.Interval, got.Repetitions, got.Lapses, got.LastReview, days(14))
+	}
+	if f.NeedsReplay(got) {
+		t.Error("NeedsReplay = true after replay")
+	}
+}
+
+func TestFSRSPriority(t *testing.T) {
+	f := FSRS{DesiredRetention: 0.9}
+	now := days(30)
+
+	cards := map[string]storage.Flashcard{
+		"new":     {},
+		"due":     {RepCount: 2, Stability: 10, LastReview: days(20)},
+		"overdue": {RepCount: 2, Stability: 10, LastReview: days(0)},
+		"sm2":     {RepCount: 1, Interval: 6, LastReview: days(25)},
+	}
+	priority := make(map[string]float64)
+	for name, card := range cards {
+		priority[name] = f.Priority(card, now)
+	}
+
+	// Reviews come first, least likely to be recalled first, then new cards
+	if !(priority["overdue"] < priority["due"] && priority["due"] < priority["sm2"] && priority["sm2"] < priority["new"]) {
+		t.Errorf("priorities %v, want overdue < due < sm2 < new", priority)
+	}
+	if !near(priority["due"], 0.9) {
+		t.Errorf("priority of a card due after its stability = %v, want 0.9", priority["due"])
+	}
+}
@@ -2,43 +2,201 @@ package scheduler
 
 import (
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/valdezdata/md-study/internal/storage"
 )
 
-// SM-2 algorithm intervals (in hours)
-var intervals = [][]int{
-	{0, 24, 144, 432}, // Easy intervals: 0h, 1d, 6d, 18d
-	{0, 8, 48, 172},   // Good intervals: 0h, 8h, 2d, 7d
-	{0, 3, 24, 72},    // Hard intervals: 0h, 3h, 1d, 3d
-	{0, 1, 3, 8},      // Again intervals: 0h, 1h, 3h, 8h
+// Ratings a card can be given during study, stored in Flashcard.Difficulty
+const (
+	Easy  = storage.RatingEasy
+	Good  = storage.RatingGood
+	Hard  = storage.RatingHard
+	Again = storage.RatingAgain
+)
+
+// Names of the available scheduling algorithms
+const (
+	AlgorithmSM2  = "sm2"
+	AlgorithmFSRS = "fsrs"
+)
+
+// Clock tells a Scheduler what time it is
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock reads the real time
+type SystemClock struct{}
+
+// Now returns the current local time
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Scheduler computes a card's scheduling state after it is rated. Implementations
+// do no I/O and take the current time only from the clock, so they are deterministic.
+type Scheduler interface {
+	// Schedule returns the card with its interval, next review and memory state
+	// updated for the rating
+	Schedule(card storage.Flashcard, rating int, clock Clock) storage.Flashcard
+}
+
+// Replayer is implemented by schedulers that can rebuild a card's state from
+// its review history, for cards that were scheduled by another algorithm
+type Replayer interface {
+	NeedsReplay(card storage.Flashcard) bool
+	Replay(card storage.Flashcard, reviews []storage.Review) storage.Flashcard
+}
+
+// Prioritizer is implemented by schedulers that can order due cards; cards
+// with a lower priority are studied first
+type Prioritizer interface {
+	Priority(card storage.Flashcard, now time.Time) float64
+}
+
+// Settings selects and tunes the scheduling algorithm
+type Settings struct {
+	Algorithm        string  // AlgorithmSM2 or AlgorithmFSRS
+	DesiredRetention float64 // Target recall probability, used by FSRS
+}
+
+// New returns the scheduler described by settings
+func New(settings Settings) (Scheduler, error) {
+	switch settings.Algorithm {
+	case "", AlgorithmSM2:
+		return SM2{}, nil
+	case AlgorithmFSRS:
+		if settings.DesiredRetention <= 0 || settings.DesiredRetention >= 1 {
+			return nil, fmt.Errorf("desired retention must be between 0 and 1, got %v", settings.DesiredRetention)
+		}
+		return FSRS{DesiredRetention: settings.DesiredRetention}, nil
+	default:
+		return nil, fmt.Errorf("unknown scheduler: %s", settings.Algorithm)
+	}
+}
+
+// Set holds the scheduler chosen for each deck
+type Set struct {
+	Default Scheduler
+	Decks   map[string]Scheduler
+}
+
+// For returns the scheduler for the named deck
+func (s Set) For(deck string) Scheduler {
+	if sched, ok := s.Decks[deck]; ok {
+		return sched
+	}
+	return s.Default
+}
+
+// forCard returns the scheduler for the deck of the note a card came from
+func (s Set) forCard(card storage.Flashcard, decks map[string]string) Scheduler {
+	return s.For(decks[card.NoteID])
+}
+
+// noteDecks maps every note ID to its deck
+func noteDecks(store storage.Store) (map[string]string, error) {
+	notes, err := store.GetAllNotes()
+	if err != nil {
+		return nil, err
+	}
+
+	decks := make(map[string]string, len(notes))
+	for _, note := range notes {
+		decks[note.ID] = note.Deck
+	}
+	return decks, nil
 }
 
-// GetDueFlashcards returns flashcards due for review
-func GetDueFlashcards() ([]storage.Flashcard, error) {
-	return storage.GetFlashcardsDueBefore(time.Now())
+// GetDueFlashcards returns flashcards due for review, in the order their
+// schedulers prioritize them: with FSRS, reviews most likely to have been
+// forgotten come first and new cards last.
+func GetDueFlashcards(store storage.Store, schedulers Set, clock Clock) ([]storage.Flashcard, error) {
+	now := clock.Now()
+
+	cards, err := store.GetFlashcardsDueBefore(now)
+	if err != nil {
+		return nil, err
+	}
+
+	decks, err := noteDecks(store)
+	if err != nil {
+		return nil, err
+	}
+
+	priority := make(map[string]float64, len(cards))
+	for _, card := range cards {
+		priority[card.ID] = 1
+		if p, ok := schedulers.forCard(card, decks).(Prioritizer); ok {
+			priority[card.ID] = p.Priority(card, now)
+		}
+	}
+
+	sort.SliceStable(cards, func(i, j int) bool {
+		return priority[cards[i].ID] < priority[cards[j].ID]
+	})
+
+	return cards, nil
 }
 
-// UpdateFlashcard updates a flashcard's difficulty and next review time
-func UpdateFlashcard(id string, difficulty int) error {
-	card, err := storage.GetFlashcard(id)
+// UpdateFlashcard reschedules a flashcard after it was rated, using the
+// scheduler for its deck, and records the rating in the review log
+func UpdateFlashcard(store storage.Store, schedulers Set, clock Clock, id string, difficulty int, responseTime time.Duration) error {
+	if difficulty < Easy || difficulty > Again {
+		return fmt.Errorf("invalid rating: %d", difficulty)
+	}
+
+	card, err := store.GetFlashcard(id)
 	if err != nil {
 		return err
 	}
 
-	// Calculate next review time based on difficulty and repetition count
-	nextInterval := intervals[difficulty][min(card.RepCount, len(intervals[difficulty])-1)]
-	card.NextReview = time.Now().Add(time.Duration(nextInterval) * time.Hour)
-	card.RepCount++
-	card.Difficulty = difficulty
+	// Cards whose note is gone use the default scheduler
+	deck := ""
+	if note, err := store.GetNote(card.NoteID); err == nil {
+		deck = note.Deck
+	}
+	sched := schedulers.For(deck)
+
+	if r, ok := sched.(Replayer); ok && r.NeedsReplay(card) {
+		reviews, err := store.GetReviews(card.ID)
+		if err != nil {
+			return err
+		}
+		card = r.Replay(card, reviews)
+	}
+
+	prevInterval := card.Interval
+	card = sched.Schedule(card, difficulty, clock)
+
+	if err := store.UpdateFlashcard(card); err != nil {
+		return err
+	}
 
-	return storage.UpdateFlashcard(card)
+	return store.AddReview(storage.Review{
+		CardID:       card.ID,
+		Rating:       difficulty,
+		ReviewedAt:   card.LastReview,
+		PrevInterval: prevInterval,
+		NewInterval:  card.Interval,
+		ResponseMs:   responseTime.Milliseconds(),
+	})
+}
+
+// finishReview sets the fields every scheduler updates the same way
+func finishReview(card storage.Flashcard, rating int, now time.Time) storage.Flashcard {
+	card.LastReview = now
+	card.NextReview = now.Add(time.Duration(card.Interval * 24 * float64(time.Hour)))
+	card.RepCount++
+	card.Difficulty = rating
+	return card
 }
 
 // ShowStats displays study statistics
-func ShowStats() {
-	stats, err := storage.GetStudyStats()
+func ShowStats(store storage.Store) {
+	stats, err := storage.GetStudyStats(store)
 	if err != nil {
 		fmt.Printf("Error getting stats: %v\n", err)
 		return
@@ -49,13 +207,9 @@ func ShowStats() {
 	fmt.Printf("Total flashcards: %d\n", stats.TotalFlashcards)
 	fmt.Printf("Cards due today: %d\n", stats.CardsDueToday)
 	fmt.Printf("Cards learned: %d\n", stats.CardsLearned)
+	fmt.Printf("Reviews: %d total, %d today\n", stats.TotalReviews, stats.ReviewsToday)
 	fmt.Printf("Review accuracy: %.1f%%\n", stats.ReviewAccuracy)
-}
-
-// min returns the smaller of a and b
-func min(a, b int) int {
-	if a < b {
-		return a
+	if stats.TotalReviews > 0 {
+		fmt.Printf("Average response time: %.1fs\n", float64(stats.AvgResponseMs)/1000)
 	}
-	return b
 }
@@ -0,0 +1,54 @@
+package scheduler
+
+import (
+	"math"
+
+	"github.com/valdezdata/md-study/internal/storage"
+)
+
+// minEaseFactor is the lowest ease factor SM-2 allows
+const minEaseFactor = 1.3
+
+// quality maps a rating to the 0-5 recall quality used by SM-2
+var quality = map[int]int{
+	Easy:  5,
+	Good:  4,
+	Hard:  3,
+	Again: 1,
+}
+
+// SM2 schedules cards with the SuperMemo 2 algorithm
+type SM2 struct{}
+
+// Schedule applies one SM-2 step: a successful recall grows the interval from
+// the previous one by the ease factor, a lapse starts the card over at one day.
+// The ease factor is then adjusted by how easy the recall was.
+func (SM2) Schedule(card storage.Flashcard, rating int, clock Clock) storage.Flashcard {
+	q := quality[rating]
+
+	ef := card.EaseFactor
+	if ef == 0 {
+		ef = storage.DefaultEaseFactor
+	}
+
+	if q >= 3 {
+		switch card.Repetitions {
+		case 0:
+			card.Interval = 1
+		case 1:
+			card.Interval = 6
+		default:
+			card.Interval = math.Round(math.Max(card.Interval, 1) * ef)
+		}
+		card.Repetitions++
+	} else {
+		card.Repetitions = 0
+		card.Interval = 1
+		card.Lapses++
+	}
+
+	ef += 0.1 - float64(5-q)*(0.08+float64(5-q)*0.02)
+	card.EaseFactor = math.Max(ef, minEaseFactor)
+
+	return finishReview(card, rating, clock.Now())
+}
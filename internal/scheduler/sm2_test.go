@@ -0,0 +1,71 @@
+package scheduler
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/valdezdata/md-study/internal/storage"
+)
+
+// fixedClock always tells the same time
+type fixedClock struct{ t time.Time }
+
+func (c fixedClock) Now() time.Time { return c.t }
+
+var testNow = time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+
+// days returns the time a number of days after testNow
+func days(n float64) time.Time {
+	return testNow.Add(time.Duration(n * 24 * float64(time.Hour)))
+}
+
+func TestSM2Schedule(t *testing.T) {
+	tests := []struct {
+		name   string
+		card   storage.Flashcard
+		rating int
+
+		interval    float64
+		repetitions int
+		lapses      int
+		ease        float64
+	}{
+		{"new easy", storage.Flashcard{}, Easy, 1, 1, 0, 2.6},
+		{"new good", storage.Flashcard{}, Good, 1, 1, 0, 2.5},
+		{"new hard", storage.Flashcard{}, Hard, 1, 1, 0, 2.36},
+		{"new again", storage.Flashcard{}, Again, 1, 0, 1, 1.96},
+		{"second recall", storage.Flashcard{EaseFactor: 2.5, Interval: 1, Repetitions: 1}, Good, 6, 2, 0, 2.5},
+		{"third recall grows by ease", storage.Flashcard{EaseFactor: 2.5, Interval: 6, Repetitions: 2}, Good, 15, 3, 0, 2.5},
+		{"easy recall grows by old ease", storage.Flashcard{EaseFactor: 2.2, Interval: 15, Repetitions: 3}, Easy, 33, 4, 0, 2.3},
+		{"lapse starts over", storage.Flashcard{EaseFactor: 2.5, Interval: 40, Repetitions: 5, Lapses: 2}, Again, 1, 0, 3, 1.96},
+		{"ease floor", storage.Flashcard{EaseFactor: 1.4, Interval: 3, Repetitions: 2}, Again, 1, 0, 1, minEaseFactor},
+		{"ease floor on hard", storage.Flashcard{EaseFactor: 1.3, Interval: 10, Repetitions: 3}, Hard, 13, 4, 0, minEaseFactor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.card.RepCount = tt.card.Repetitions + tt.card.Lapses
+			got := SM2{}.Schedule(tt.card, tt.rating, fixedClock{testNow})
+
+			if got.Interval != tt.interval {
+				t.Errorf("Interval = %v, want %v", got.Interval, tt.interval)
+			}
+			if got.Repetitions != tt.repetitions {
+				t.Errorf("Repetitions = %d, want %d", got.Repetitions, tt.repetitions)
+			}
+			if got.Lapses != tt.lapses {
+				t.Errorf("Lapses = %d, want %d", got.Lapses, tt.lapses)
+			}
+			if math.Abs(got.EaseFactor-tt.ease) > 1e-9 {
+				t.Errorf("EaseFactor = %v, want %v", got.EaseFactor, tt.ease)
+			}
+			if !got.LastReview.Equal(testNow) || !got.NextReview.Equal(days(tt.interval)) {
+				t.Errorf("reviewed %v, next %v, want %v and %v", got.LastReview, got.NextReview, testNow, days(tt.interval))
+			}
+			if got.RepCount != tt.card.RepCount+1 || got.Difficulty != tt.rating {
+				t.Errorf("RepCount %d, Difficulty %d, want %d and %d", got.RepCount, got.Difficulty, tt.card.RepCount+1, tt.rating)
+			}
+		})
+	}
+}
@@ -0,0 +1,382 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	notesFile   = "notes.json"
+	cardsFile   = "flashcards.json"
+	reviewsFile = "reviews.jsonl"
+)
+
+var _ Store = (*JSONStore)(nil)
+
+// JSONStore keeps notes and flashcards in JSON files and the review history
+// in a JSON Lines file, all inside a single data directory. Files are replaced
+// atomically, and the directory is locked for as long as the store is open.
+type JSONStore struct {
+	dir  string
+	lock *dirLock
+}
+
+// NewJSONStore returns a store backed by the files in dir, creating them if needed.
+// It fails with ErrLocked if another process has the directory open.
+func NewJSONStore(dir string) (*JSONStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	lock, err := lockDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &JSONStore{dir: dir, lock: lock}
+	if err := s.initialize(); err != nil {
+		lock.release()
+		return nil, err
+	}
+	return s, nil
+}
+
+// initialize creates the storage files if they don't exist
+func (s *JSONStore) initialize() error {
+	for _, file := range []string{notesFile, cardsFile} {
+		path := s.path(file)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			if err := writeFileAtomic(path, []byte("[]"), 0644); err != nil {
+				return fmt.Errorf("failed to initialize %s: %w", file, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// path returns the full path to a storage file
+func (s *JSONStore) path(filename string) string {
+	return filepath.Join(s.dir, filename)
+}
+
+// readNotes loads every note from the notes file
+func (s *JSONStore) readNotes() ([]Note, error) {
+	data, err := os.ReadFile(s.path(notesFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notes file: %w", err)
+	}
+
+	var notes []Note
+	if err := json.Unmarshal(data, &notes); err != nil {
+		return nil, fmt.Errorf("failed to parse notes: %w", err)
+	}
+
+	return notes, nil
+}
+
+// writeNotes replaces the contents of the notes file
+func (s *JSONStore) writeNotes(notes []Note) error {
+	data, err := json.MarshalIndent(notes, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal notes: %w", err)
+	}
+
+	if err := writeFileAtomic(s.path(notesFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to write notes file: %w", err)
+	}
+
+	return nil
+}
+
+// readCards loads every flashcard from the flashcards file
+func (s *JSONStore) readCards() ([]Flashcard, error) {
+	data, err := os.ReadFile(s.path(cardsFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read flashcards file: %w", err)
+	}
+
+	var cards []Flashcard
+	if err := json.Unmarshal(data, &cards); err != nil {
+		return nil, fmt.Errorf("failed to parse flashcards: %w", err)
+	}
+
+	for i := range cards {
+		upgradeFlashcard(&cards[i])
+	}
+
+	return cards, nil
+}
+
+// writeCards replaces the contents of the flashcards file
+func (s *JSONStore) writeCards(cards []Flashcard) error {
+	if cards == nil {
+		cards = []Flashcard{}
+	}
+
+	data, err := json.MarshalIndent(cards, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal flashcards: %w", err)
+	}
+
+	if err := writeFileAtomic(s.path(cardsFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to write flashcards file: %w", err)
+	}
+
+	return nil
+}
+
+// SaveNote saves a note to storage
+func (s *JSONStore) SaveNote(note Note) error {
+	// Generate ID if not already set
+	if note.ID == "" {
+		note.ID = uuid.New().String()
+	}
+
+	notes, err := s.readNotes()
+	if err != nil {
+		return err
+	}
+
+	// Check if note exists and update or add
+	found := false
+	for i, n := range notes {
+		if n.ID == note.ID || n.FilePath == note.FilePath {
+			notes[i] = note
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		notes = append(notes, note)
+	}
+
+	return s.writeNotes(notes)
+}
+
+// GetNote retrieves a note by ID
+func (s *JSONStore) GetNote(id string) (Note, error) {
+	notes, err := s.readNotes()
+	if err != nil {
+		return Note{}, err
+	}
+
+	for _, note := range notes {
+		if note.ID == id {
+			return note, nil
+		}
+	}
+
+	return Note{}, fmt.Errorf("note not found: %s", id)
+}
+
+// GetAllNotes retrieves all notes
+func (s *JSONStore) GetAllNotes() ([]Note, error) {
+	return s.readNotes()
+}
+
+// DeleteNote removes a note by ID
+func (s *JSONStore) DeleteNote(id string) error {
+	notes, err := s.readNotes()
+	if err != nil {
+		return err
+	}
+
+	for i, note := range notes {
+		if note.ID == id {
+			return s.writeNotes(append(notes[:i], notes[i+1:]...))
+		}
+	}
+
+	return fmt.Errorf("note not found: %s", id)
+}
+
+// SaveFlashcard saves a flashcard to storage
+func (s *JSONStore) SaveFlashcard(card Flashcard) error {
+	// Generate ID if not already set
+	if card.ID == "" {
+		card.ID = uuid.New().String()
+	}
+	upgradeFlashcard(&card)
+
+	cards, err := s.readCards()
+	if err != nil {
+		return err
+	}
+
+	// Check if card exists and update or add
+	found := false
+	for i, c := range cards {
+		if c.ID == card.ID {
+			cards[i] = card
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		cards = append(cards, card)
+	}
+
+	return s.writeCards(cards)
+}
+
+// GetFlashcard retrieves a flashcard by ID
+func (s *JSONStore) GetFlashcard(id string) (Flashcard, error) {
+	cards, err := s.readCards()
+	if err != nil {
+		return Flashcard{}, err
+	}
+
+	for _, card := range cards {
+		if card.ID == id {
+			return card, nil
+		}
+	}
+
+	return Flashcard{}, fmt.Errorf("flashcard not found: %s", id)
+}
+
+// UpdateFlashcard updates an existing flashcard
+func (s *JSONStore) UpdateFlashcard(card Flashcard) error {
+	return s.SaveFlashcard(card)
+}
+
+// GetFlashcardsDueBefore returns all flashcards due before the given time
+func (s *JSONStore) GetFlashcardsDueBefore(t time.Time) ([]Flashcard, error) {
+	cards, err := s.readCards()
+	if err != nil {
+		return nil, err
+	}
+
+	var dueCards []Flashcard
+	for _, card := range cards {
+		if card.Status == StatusActive && card.NextReview.Before(t) {
+			dueCards = append(dueCards, card)
+		}
+	}
+
+	return dueCards, nil
+}
+
+// GetAllFlashcards retrieves all flashcards
+func (s *JSONStore) GetAllFlashcards() ([]Flashcard, error) {
+	return s.readCards()
+}
+
+// DeleteFlashcard removes a flashcard by ID
+func (s *JSONStore) DeleteFlashcard(id string) error {
+	cards, err := s.readCards()
+	if err != nil {
+		return err
+	}
+
+	// Filter out the card to be deleted
+	var newCards []Flashcard
+	found := false
+	for _, card := range cards {
+		if card.ID != id {
+			newCards = append(newCards, card)
+		} else {
+			found = true
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("flashcard not found: %s", id)
+	}
+
+	return s.writeCards(newCards)
+}
+
+// DeleteAllFlashcards removes all flashcards
+func (s *JSONStore) DeleteAllFlashcards() error {
+	return s.writeCards(nil)
+}
+
+// AddReview appends a review to the review log
+func (s *JSONStore) AddReview(review Review) error {
+	if review.ID == "" {
+		review.ID = uuid.New().String()
+	}
+
+	data, err := json.Marshal(review)
+	if err != nil {
+		return fmt.Errorf("failed to marshal review: %w", err)
+	}
+
+	f, err := os.OpenFile(s.path(reviewsFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open reviews file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write review: %w", err)
+	}
+
+	return nil
+}
+
+// GetReviews returns the review history of a single flashcard, oldest first
+func (s *JSONStore) GetReviews(cardID string) ([]Review, error) {
+	reviews, err := s.GetAllReviews()
+	if err != nil {
+		return nil, err
+	}
+
+	var cardReviews []Review
+	for _, review := range reviews {
+		if review.CardID == cardID {
+			cardReviews = append(cardReviews, review)
+		}
+	}
+
+	return cardReviews, nil
+}
+
+// GetAllReviews returns the whole review history, oldest first
+func (s *JSONStore) GetAllReviews() ([]Review, error) {
+	data, err := os.ReadFile(s.path(reviewsFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read reviews file: %w", err)
+	}
+
+	var reviews []Review
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var review Review
+		if err := json.Unmarshal(line, &review); err != nil {
+			return nil, fmt.Errorf("failed to parse review: %w", err)
+		}
+		reviews = append(reviews, review)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read reviews file: %w", err)
+	}
+
+	return reviews, nil
+}
+
+// Close releases the data directory lock
+func (s *JSONStore) Close() error {
+	return s.lock.release()
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const lockFileName = ".lock"
+
+// ErrLocked is returned when another md-study process holds the data directory lock
+var ErrLocked = errors.New("data directory is in use by another md-study process")
+
+// dirLock is an advisory lock on a data directory, held until released
+type dirLock struct {
+	f *os.File
+}
+
+// lockDir takes an exclusive advisory lock on dir without waiting
+func lockDir(dir string) (*dirLock, error) {
+	path := filepath.Join(dir, lockFileName)
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	if err := lockFile(f); err != nil {
+		f.Close()
+		if errors.Is(err, errWouldBlock) {
+			if pid := readLockOwner(path); pid != "" {
+				return nil, fmt.Errorf("%w (pid %s holds %s)", ErrLocked, pid, path)
+			}
+			return nil, fmt.Errorf("%w (%s is locked)", ErrLocked, path)
+		}
+		return nil, fmt.Errorf("failed to lock data directory: %w", err)
+	}
+
+	// Record our PID so a blocked process can say who holds the lock
+	if err := recordLockOwner(f); err != nil {
+		unlockFile(f)
+		f.Close()
+		return nil, fmt.Errorf("failed to write lock file: %w", err)
+	}
+
+	return &dirLock{f: f}, nil
+}
+
+// recordLockOwner replaces the contents of the lock file with our PID
+func recordLockOwner(f *os.File) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	return err
+}
+
+// release drops the lock; it is also dropped automatically when the process exits
+func (l *dirLock) release() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+	l.f.Truncate(0)
+	unlockFile(l.f)
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
+
+// readLockOwner returns the PID recorded in the lock file, if any
+func readLockOwner(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
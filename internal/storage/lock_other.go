@@ -0,0 +1,20 @@
+//go:build !unix && !windows
+
+package storage
+
+import (
+	"errors"
+	"os"
+)
+
+var errWouldBlock = errors.New("lock held")
+
+// lockFile is a no-op on platforms without advisory file locks
+func lockFile(f *os.File) error {
+	return nil
+}
+
+// unlockFile is a no-op on platforms without advisory file locks
+func unlockFile(f *os.File) error {
+	return nil
+}
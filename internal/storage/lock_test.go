@@ -0,0 +1,38 @@
+//go:build unix || windows
+
+package storage
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLockDirNamesOwner(t *testing.T) {
+	dir := t.TempDir()
+
+	lock, err := lockDir(dir)
+	if err != nil {
+		t.Fatalf("lockDir: %v", err)
+	}
+
+	// A second lock, as another process would take, fails and names our PID
+	_, err = lockDir(dir)
+	if !errors.Is(err, ErrLocked) {
+		t.Fatalf("second lockDir: %v, want ErrLocked", err)
+	}
+	if pid := "pid " + strconv.Itoa(os.Getpid()); !strings.Contains(err.Error(), pid) {
+		t.Errorf("second lockDir: %v, want it to name %s", err, pid)
+	}
+
+	if err := lock.release(); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	again, err := lockDir(dir)
+	if err != nil {
+		t.Fatalf("lockDir after release: %v", err)
+	}
+	again.release()
+}
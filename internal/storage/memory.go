@@ -0,0 +1,209 @@
+package storage
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var _ Store = (*MemoryStore)(nil)
+
+// MemoryStore keeps everything in memory and is mainly useful for tests
+type MemoryStore struct {
+	mu      sync.Mutex
+	notes   []Note
+	cards   []Flashcard
+	reviews []Review
+}
+
+// NewMemoryStore returns an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// SaveNote saves a note to storage
+func (s *MemoryStore) SaveNote(note Note) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if note.ID == "" {
+		note.ID = uuid.New().String()
+	}
+
+	for i, n := range s.notes {
+		if n.ID == note.ID || n.FilePath == note.FilePath {
+			s.notes[i] = note
+			return nil
+		}
+	}
+
+	s.notes = append(s.notes, note)
+	return nil
+}
+
+// GetNote retrieves a note by ID
+func (s *MemoryStore) GetNote(id string) (Note, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, note := range s.notes {
+		if note.ID == id {
+			return note, nil
+		}
+	}
+
+	return Note{}, fmt.Errorf("note not found: %s", id)
+}
+
+// GetAllNotes retrieves all notes
+func (s *MemoryStore) GetAllNotes() ([]Note, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Note(nil), s.notes...), nil
+}
+
+// DeleteNote removes a note by ID
+func (s *MemoryStore) DeleteNote(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, note := range s.notes {
+		if note.ID == id {
+			s.notes = append(s.notes[:i], s.notes[i+1:]...)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("note not found: %s", id)
+}
+
+// SaveFlashcard saves a flashcard to storage
+func (s *MemoryStore) SaveFlashcard(card Flashcard) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if card.ID == "" {
+		card.ID = uuid.New().String()
+	}
+	upgradeFlashcard(&card)
+
+	for i, c := range s.cards {
+		if c.ID == card.ID {
+			s.cards[i] = card
+			return nil
+		}
+	}
+
+	s.cards = append(s.cards, card)
+	return nil
+}
+
+// GetFlashcard retrieves a flashcard by ID
+func (s *MemoryStore) GetFlashcard(id string) (Flashcard, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, card := range s.cards {
+		if card.ID == id {
+			return card, nil
+		}
+	}
+
+	return Flashcard{}, fmt.Errorf("flashcard not found: %s", id)
+}
+
+// UpdateFlashcard updates an existing flashcard
+func (s *MemoryStore) UpdateFlashcard(card Flashcard) error {
+	return s.SaveFlashcard(card)
+}
+
+// GetAllFlashcards retrieves all flashcards
+func (s *MemoryStore) GetAllFlashcards() ([]Flashcard, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Flashcard(nil), s.cards...), nil
+}
+
+// GetFlashcardsDueBefore returns all flashcards due before the given time
+func (s *MemoryStore) GetFlashcardsDueBefore(t time.Time) ([]Flashcard, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var dueCards []Flashcard
+	for _, card := range s.cards {
+		if card.Status == StatusActive && card.NextReview.Before(t) {
+			dueCards = append(dueCards, card)
+		}
+	}
+
+	return dueCards, nil
+}
+
+// DeleteFlashcard removes a flashcard by ID
+func (s *MemoryStore) DeleteFlashcard(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, card := range s.cards {
+		if card.ID == id {
+			s.cards = append(s.cards[:i], s.cards[i+1:]...)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("flashcard not found: %s", id)
+}
+
+// DeleteAllFlashcards removes all flashcards
+func (s *MemoryStore) DeleteAllFlashcards() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cards = nil
+	return nil
+}
+
+// AddReview appends a review to the review log
+func (s *MemoryStore) AddReview(review Review) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if review.ID == "" {
+		review.ID = uuid.New().String()
+	}
+
+	s.reviews = append(s.reviews, review)
+	return nil
+}
+
+// GetReviews returns the review history of a single flashcard, oldest first
+func (s *MemoryStore) GetReviews(cardID string) ([]Review, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var cardReviews []Review
+	for _, review := range s.reviews {
+		if review.CardID == cardID {
+			cardReviews = append(cardReviews, review)
+		}
+	}
+
+	return cardReviews, nil
+}
+
+// GetAllReviews returns the whole review history, oldest first
+func (s *MemoryStore) GetAllReviews() ([]Review, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Review(nil), s.reviews...), nil
+}
+
+// Close does nothing for an in-memory store
+func (s *MemoryStore) Close() error {
+	return nil
+}
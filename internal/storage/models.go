@@ -6,24 +6,134 @@ import (
 
 // Note represents a markdown note
 type Note struct {
-	ID         string    `json:"id"`
-	FilePath   string    `json:"file_path"`
-	Filename   string    `json:"filename"`
-	RawContent string    `json:"raw_content"`
-	LastImport time.Time `json:"last_import"`
-	Flashcards []string  `json:"flashcard_ids"`
+	ID          string    `json:"id"`
+	FilePath    string    `json:"file_path"`
+	Filename    string    `json:"filename"`
+	Deck        string    `json:"deck,omitempty"` // Deck the note was imported into
+	Title       string    `json:"title,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	RawContent  string    `json:"raw_content"`
+	ContentHash string    `json:"content_hash,omitempty"` // SHA-256 of the imported file
+	CardsHash   string    `json:"cards_hash,omitempty"`   // ContentHash the flashcards were generated from
+	Orphaned    bool      `json:"orphaned,omitempty"`     // The source file no longer exists
+	LastImport  time.Time `json:"last_import"`
+	Flashcards  []string  `json:"flashcard_ids"`
+
+	// Front matter keys other than title, tags and study
+	Metadata map[string]any `json:"metadata,omitempty"`
 }
 
+// Ratings a card can be given during study, stored in Flashcard.Difficulty
+// and Review.Rating
+const (
+	RatingEasy = iota
+	RatingGood
+	RatingHard
+	RatingAgain
+)
+
+// DefaultEaseFactor is the SM-2 ease factor given to new cards
+const DefaultEaseFactor = 2.5
+
+// Flashcard statuses; only active cards are scheduled for study
+const (
+	StatusActive   = "active"
+	StatusOrphaned = "orphaned" // The note's source file was deleted
+	StatusArchived = "archived" // Kept with its history but never studied
+
+	// Generated with an answer that couldn't be found in its note; held out of
+	// study until it is accepted
+	StatusUnverified = "unverified"
+
+	// Newly generated and held out of study until it is reviewed
+	StatusPending = "pending"
+)
+
+// Flashcard types
+const (
+	TypeBasic = "basic" // A question and its answer
+	TypeCloze = "cloze" // A text with deletions to recall; Question holds the text
+)
+
+// Flashcard sources
+const (
+	SourceGenerated = "generated" // Created by a model from the note
+	SourceInline    = "inline"    // Written in the note itself
+)
+
 // Flashcard represents a question-answer pair for studying
 type Flashcard struct {
-	ID         string    `json:"id"`
-	NoteID     string    `json:"note_id"`
-	Question   string    `json:"question"`
-	Answer     string    `json:"answer"`
-	Difficulty int       `json:"difficulty"` // 0-3: Easy, Good, Hard, Again
-	RepCount   int       `json:"rep_count"`  // Number of repetitions
-	LastReview time.Time `json:"last_review"`
-	NextReview time.Time `json:"next_review"`
+	ID          string    `json:"id"`
+	NoteID      string    `json:"note_id"`
+	Type        string    `json:"type"`
+	Question    string    `json:"question"`
+	Answer      string    `json:"answer"`
+	Difficulty  int       `json:"difficulty"`    // 0-3: Easy, Good, Hard, Again
+	RepCount    int       `json:"rep_count"`     // Number of repetitions
+	EaseFactor  float64   `json:"ease_factor"`   // SM-2 ease factor, at least 1.3
+	Interval    float64   `json:"interval_days"` // Current review interval, in days
+	Repetitions int       `json:"repetitions"`   // Successful reviews since the last lapse
+	Lapses      int       `json:"lapses"`        // Number of times the card was forgotten
+	LastReview  time.Time `json:"last_review"`
+	NextReview  time.Time `json:"next_review"`
+	Status      string    `json:"status"`
+	Source      string    `json:"source"`
+	Tags        []string  `json:"tags,omitempty"`    // Tags of the note the card came from, and any the model gave it
+	Section     []string  `json:"section,omitempty"` // Heading path of the note section the card was generated from
+
+	// Cloze cards hold the text with its deletions in Question, and the text
+	// hidden by the deletions numbered ClozeIndex in Answer
+	ClozeIndex int `json:"cloze_index,omitempty"`
+
+	// Passage of the note a generated card is based on, as quoted by the model,
+	// and the lines of the note's raw content it spans, from 1; 0 if not found
+	SourceQuote string `json:"source_quote,omitempty"`
+	SourceStart int    `json:"source_start,omitempty"`
+	SourceEnd   int    `json:"source_end,omitempty"`
+
+	// FSRS memory state; zero until the card is first scheduled with FSRS
+	Stability      float64 `json:"stability,omitempty"`       // Days until recall probability drops to 90%
+	FSRSDifficulty float64 `json:"fsrs_difficulty,omitempty"` // 1 (easy) to 10 (hard)
+}
+
+// upgradeFlashcard fills in fields missing from cards created by older versions
+// or without them set: the type, status and source, and the scheduling fields of
+// cards saved before SM-2 scheduling was introduced, which have no ease factor yet
+func upgradeFlashcard(card *Flashcard) {
+	if card.Type == "" {
+		card.Type = TypeBasic
+	}
+	if card.Status == "" {
+		card.Status = StatusActive
+	}
+	if card.Source == "" {
+		card.Source = SourceGenerated
+	}
+
+	if card.EaseFactor != 0 {
+		return
+	}
+
+	card.EaseFactor = DefaultEaseFactor
+	if !card.LastReview.IsZero() && card.NextReview.After(card.LastReview) {
+		card.Interval = card.NextReview.Sub(card.LastReview).Hours() / 24
+	}
+
+	// Without a history we assume every review up to a failed one succeeded
+	if card.Difficulty != RatingAgain {
+		card.Repetitions = card.RepCount
+	}
+}
+
+// Review records a single rating given to a flashcard
+type Review struct {
+	ID           string    `json:"id"`
+	CardID       string    `json:"card_id"`
+	Rating       int       `json:"rating"` // 0-3: Easy, Good, Hard, Again
+	ReviewedAt   time.Time `json:"reviewed_at"`
+	PrevInterval float64   `json:"prev_interval_days"` // Interval before this review, in days
+	NewInterval  float64   `json:"new_interval_days"`  // Interval scheduled by this review, in days
+	ResponseMs   int64     `json:"response_ms"`        // Time taken to recall the answer
 }
 
 // StudyStats represents study statistics
@@ -32,5 +142,8 @@ type StudyStats struct {
 	TotalFlashcards int     `json:"total_flashcards"`
 	CardsDueToday   int     `json:"cards_due_today"`
 	CardsLearned    int     `json:"cards_learned"`
+	TotalReviews    int     `json:"total_reviews"`
+	ReviewsToday    int     `json:"reviews_today"`
 	ReviewAccuracy  float64 `json:"review_accuracy"`
+	AvgResponseMs   int64   `json:"avg_response_ms"`
 }
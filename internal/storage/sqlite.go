@@ -0,0 +1,603 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	_ "modernc.org/sqlite"
+)
+
+// timeLayout is fixed-width so that timestamps stored as text sort chronologically
+const timeLayout = "2006-01-02T15:04:05.000000000Z"
+
+// migrations are applied in order; PRAGMA user_version records how many have run
+var migrations = []string{
+	`CREATE TABLE notes (
+		id            TEXT PRIMARY KEY,
+		file_path     TEXT NOT NULL UNIQUE,
+		filename      TEXT NOT NULL,
+		raw_content   TEXT NOT NULL,
+		last_import   TEXT NOT NULL,
+		flashcard_ids TEXT NOT NULL DEFAULT '[]'
+	);
+	CREATE TABLE flashcards (
+		id          TEXT PRIMARY KEY,
+		note_id     TEXT NOT NULL,
+		question    TEXT NOT NULL,
+		answer      TEXT NOT NULL,
+		difficulty  INTEGER NOT NULL DEFAULT 0,
+		rep_count   INTEGER NOT NULL DEFAULT 0,
+		last_review TEXT NOT NULL,
+		next_review TEXT NOT NULL,
+		position    INTEGER NOT NULL
+	);
+	CREATE INDEX idx_flashcards_next_review ON flashcards(next_review);
+	CREATE INDEX idx_flashcards_note_id ON flashcards(note_id);
+	CREATE TABLE reviews (
+		id          TEXT PRIMARY KEY,
+		card_id     TEXT NOT NULL,
+		rating      INTEGER NOT NULL,
+		reviewed_at TEXT NOT NULL
+	);
+	CREATE INDEX idx_reviews_card_id ON reviews(card_id, reviewed_at);
+	CREATE TABLE meta (
+		key   TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	);`,
+	`ALTER TABLE reviews ADD COLUMN prev_interval_days REAL NOT NULL DEFAULT 0;
+	ALTER TABLE reviews ADD COLUMN new_interval_days REAL NOT NULL DEFAULT 0;
+	ALTER TABLE reviews ADD COLUMN response_ms INTEGER NOT NULL DEFAULT 0;`,
+	`ALTER TABLE flashcards ADD COLUMN ease_factor REAL NOT NULL DEFAULT 0;
+	ALTER TABLE flashcards ADD COLUMN interval_days REAL NOT NULL DEFAULT 0;
+	ALTER TABLE flashcards ADD COLUMN repetitions INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE flashcards ADD COLUMN lapses INTEGER NOT NULL DEFAULT 0;`,
+	`ALTER TABLE flashcards ADD COLUMN stability REAL NOT NULL DEFAULT 0;
+	ALTER TABLE flashcards ADD COLUMN fsrs_difficulty REAL NOT NULL DEFAULT 0;`,
+	`ALTER TABLE notes ADD COLUMN deck TEXT NOT NULL DEFAULT '';`,
+	`ALTER TABLE notes ADD COLUMN content_hash TEXT NOT NULL DEFAULT '';
+	ALTER TABLE notes ADD COLUMN cards_hash TEXT NOT NULL DEFAULT '';`,
+	`ALTER TABLE notes ADD COLUMN orphaned INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE flashcards ADD COLUMN status TEXT NOT NULL DEFAULT 'active';
+	CREATE INDEX idx_flashcards_due ON flashcards(status, next_review);`,
+	`ALTER TABLE notes ADD COLUMN title TEXT NOT NULL DEFAULT '';
+	ALTER TABLE notes ADD COLUMN tags TEXT NOT NULL DEFAULT '[]';
+	ALTER TABLE notes ADD COLUMN metadata TEXT NOT NULL DEFAULT '{}';
+	ALTER TABLE flashcards ADD COLUMN tags TEXT NOT NULL DEFAULT '[]';`,
+	`ALTER TABLE flashcards ADD COLUMN section TEXT NOT NULL DEFAULT '[]';`,
+	`ALTER TABLE flashcards ADD COLUMN source TEXT NOT NULL DEFAULT 'generated';`,
+	`ALTER TABLE flashcards ADD COLUMN type TEXT NOT NULL DEFAULT 'basic';
+	ALTER TABLE flashcards ADD COLUMN cloze_index INTEGER NOT NULL DEFAULT 0;`,
+	`ALTER TABLE flashcards ADD COLUMN source_quote TEXT NOT NULL DEFAULT '';`,
+	`ALTER TABLE flashcards ADD COLUMN source_start INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE flashcards ADD COLUMN source_end INTEGER NOT NULL DEFAULT 0;`,
+}
+
+var _ Store = (*SQLiteStore)(nil)
+
+// SQLiteStore keeps notes, flashcards and review history in a single-file
+// embedded SQLite database
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore opens (or creates) the database at path and brings its schema up to date
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	// SQLite allows a single writer; one connection avoids SQLITE_BUSY between our own goroutines
+	db.SetMaxOpenConns(1)
+
+	s := &SQLiteStore{db: db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// migrate applies any schema migrations that haven't run yet
+func (s *SQLiteStore) migrate() error {
+	var version int
+	if err := s.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin migration: %w", err)
+		}
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to apply migration %d: %w", i+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record migration %d: %w", i+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit migration %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+// Close closes the underlying database
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// ImportJSON copies notes, flashcards and reviews from a JSON store the first
+// time it is called; later calls do nothing
+func (s *SQLiteStore) ImportJSON(src *JSONStore) (bool, error) {
+	var done string
+	err := s.db.QueryRow("SELECT value FROM meta WHERE key = 'json_imported'").Scan(&done)
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return false, fmt.Errorf("failed to read migration state: %w", err)
+	}
+
+	notes, err := src.GetAllNotes()
+	if err != nil {
+		return false, err
+	}
+	cards, err := src.GetAllFlashcards()
+	if err != nil {
+		return false, err
+	}
+	reviews, err := src.GetAllReviews()
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin migration: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, note := range notes {
+		if err := saveNote(tx, note); err != nil {
+			return false, err
+		}
+	}
+	for _, card := range cards {
+		if err := saveFlashcard(tx, card); err != nil {
+			return false, err
+		}
+	}
+	for _, review := range reviews {
+		if err := addReview(tx, review); err != nil {
+			return false, err
+		}
+	}
+
+	if _, err := tx.Exec("INSERT INTO meta (key, value) VALUES ('json_imported', ?)", formatTime(time.Now())); err != nil {
+		return false, fmt.Errorf("failed to record migration state: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit migration: %w", err)
+	}
+
+	return true, nil
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// formatTime converts a time to its stored text form
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
+
+// parseTime converts a stored timestamp back to local time
+func parseTime(s string) (time.Time, error) {
+	t, err := time.Parse(timeLayout, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse timestamp %q: %w", s, err)
+	}
+	if t.IsZero() {
+		return time.Time{}, nil
+	}
+	return t.Local(), nil
+}
+
+const noteColumns = "id, file_path, filename, raw_content, last_import, flashcard_ids, deck, content_hash, cards_hash, orphaned, " +
+	"title, tags, metadata"
+
+// scanNote reads a note from a row selected with noteColumns
+func scanNote(row rowScanner) (Note, error) {
+	var note Note
+	var lastImport, flashcardIDs, tags, metadata string
+	if err := row.Scan(&note.ID, &note.FilePath, &note.Filename, &note.RawContent, &lastImport, &flashcardIDs, &note.Deck,
+		&note.ContentHash, &note.CardsHash, &note.Orphaned, &note.Title, &tags, &metadata); err != nil {
+		return Note{}, err
+	}
+
+	var err error
+	if note.LastImport, err = parseTime(lastImport); err != nil {
+		return Note{}, err
+	}
+	if err := json.Unmarshal([]byte(flashcardIDs), &note.Flashcards); err != nil {
+		return Note{}, fmt.Errorf("failed to parse flashcard IDs: %w", err)
+	}
+	if err := json.Unmarshal([]byte(tags), &note.Tags); err != nil {
+		return Note{}, fmt.Errorf("failed to parse tags: %w", err)
+	}
+	if err := json.Unmarshal([]byte(metadata), &note.Metadata); err != nil {
+		return Note{}, fmt.Errorf("failed to parse metadata: %w", err)
+	}
+	if len(note.Metadata) == 0 {
+		note.Metadata = nil
+	}
+
+	return note, nil
+}
+
+// saveNote inserts a note or replaces the one with the same ID or file path
+func saveNote(db execer, note Note) error {
+	flashcardIDs, err := json.Marshal(note.Flashcards)
+	if err != nil {
+		return fmt.Errorf("failed to marshal flashcard IDs: %w", err)
+	}
+	if note.Flashcards == nil {
+		flashcardIDs = []byte("[]")
+	}
+	tags, err := marshalTags(note.Tags)
+	if err != nil {
+		return err
+	}
+	metadata, err := json.Marshal(note.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	if note.Metadata == nil {
+		metadata = []byte("{}")
+	}
+
+	// A different note at the same path is replaced, as in the JSON store
+	if _, err := db.Exec("DELETE FROM notes WHERE file_path = ? AND id <> ?", note.FilePath, note.ID); err != nil {
+		return fmt.Errorf("failed to save note: %w", err)
+	}
+
+	_, err = db.Exec(`INSERT INTO notes (`+noteColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			file_path = excluded.file_path,
+			filename = excluded.filename,
+			raw_content = excluded.raw_content,
+			last_import = excluded.last_import,
+			flashcard_ids = excluded.flashcard_ids,
+			deck = excluded.deck,
+			content_hash = excluded.content_hash,
+			cards_hash = excluded.cards_hash,
+			orphaned = excluded.orphaned,
+			title = excluded.title,
+			tags = excluded.tags,
+			metadata = excluded.metadata`,
+		note.ID, note.FilePath, note.Filename, note.RawContent, formatTime(note.LastImport), string(flashcardIDs), note.Deck,
+		note.ContentHash, note.CardsHash, note.Orphaned, note.Title, tags, string(metadata))
+	if err != nil {
+		return fmt.Errorf("failed to save note: %w", err)
+	}
+
+	return nil
+}
+
+// SaveNote saves a note to storage
+func (s *SQLiteStore) SaveNote(note Note) error {
+	if note.ID == "" {
+		note.ID = uuid.New().String()
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := saveNote(tx, note); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetNote retrieves a note by ID
+func (s *SQLiteStore) GetNote(id string) (Note, error) {
+	note, err := scanNote(s.db.QueryRow("SELECT "+noteColumns+" FROM notes WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Note{}, fmt.Errorf("note not found: %s", id)
+	}
+	if err != nil {
+		return Note{}, fmt.Errorf("failed to get note: %w", err)
+	}
+	return note, nil
+}
+
+// DeleteNote removes a note by ID
+func (s *SQLiteStore) DeleteNote(id string) error {
+	res, err := s.db.Exec("DELETE FROM notes WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete note: %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("note not found: %s", id)
+	}
+
+	return nil
+}
+
+// GetAllNotes retrieves all notes
+func (s *SQLiteStore) GetAllNotes() ([]Note, error) {
+	rows, err := s.db.Query("SELECT " + noteColumns + " FROM notes ORDER BY rowid")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notes: %w", err)
+	}
+	defer rows.Close()
+
+	var notes []Note
+	for rows.Next() {
+		note, err := scanNote(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read note: %w", err)
+		}
+		notes = append(notes, note)
+	}
+
+	return notes, rows.Err()
+}
+
+const cardColumns = "id, note_id, question, answer, difficulty, rep_count, last_review, next_review, " +
+	"ease_factor, interval_days, repetitions, lapses, stability, fsrs_difficulty, status, tags, section, source, type, cloze_index, source_quote, source_start, source_end"
+
+// scanFlashcard reads a flashcard from a row selected with cardColumns
+func scanFlashcard(row rowScanner) (Flashcard, error) {
+	var card Flashcard
+	var lastReview, nextReview, tags, section string
+	if err := row.Scan(&card.ID, &card.NoteID, &card.Question, &card.Answer, &card.Difficulty, &card.RepCount, &lastReview, &nextReview,
+		&card.EaseFactor, &card.Interval, &card.Repetitions, &card.Lapses, &card.Stability, &card.FSRSDifficulty, &card.Status,
+		&tags, &section, &card.Source, &card.Type, &card.ClozeIndex, &card.SourceQuote, &card.SourceStart, &card.SourceEnd); err != nil {
+		return Flashcard{}, err
+	}
+	if err := json.Unmarshal([]byte(tags), &card.Tags); err != nil {
+		return Flashcard{}, fmt.Errorf("failed to parse tags: %w", err)
+	}
+	if err := json.Unmarshal([]byte(section), &card.Section); err != nil {
+		return Flashcard{}, fmt.Errorf("failed to parse section: %w", err)
+	}
+
+	var err error
+	if card.LastReview, err = parseTime(lastReview); err != nil {
+		return Flashcard{}, err
+	}
+	if card.NextReview, err = parseTime(nextReview); err != nil {
+		return Flashcard{}, err
+	}
+	upgradeFlashcard(&card)
+
+	return card, nil
+}
+
+// queryFlashcards runs a query selecting cardColumns and collects the results
+func (s *SQLiteStore) queryFlashcards(query string, args ...any) ([]Flashcard, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query flashcards: %w", err)
+	}
+	defer rows.Close()
+
+	var cards []Flashcard
+	for rows.Next() {
+		card, err := scanFlashcard(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read flashcard: %w", err)
+		}
+		cards = append(cards, card)
+	}
+
+	return cards, rows.Err()
+}
+
+// saveFlashcard inserts a flashcard or replaces the one with the same ID,
+// keeping its original position in listings
+func saveFlashcard(db execer, card Flashcard) error {
+	upgradeFlashcard(&card)
+
+	tags, err := marshalTags(card.Tags)
+	if err != nil {
+		return err
+	}
+	section, err := marshalTags(card.Section)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`INSERT INTO flashcards (`+cardColumns+`, position)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, (SELECT COALESCE(MAX(position), 0) + 1 FROM flashcards))
+		ON CONFLICT(id) DO UPDATE SET
+			note_id = excluded.note_id,
+			question = excluded.question,
+			answer = excluded.answer,
+			difficulty = excluded.difficulty,
+			rep_count = excluded.rep_count,
+			last_review = excluded.last_review,
+			next_review = excluded.next_review,
+			ease_factor = excluded.ease_factor,
+			interval_days = excluded.interval_days,
+			repetitions = excluded.repetitions,
+			lapses = excluded.lapses,
+			stability = excluded.stability,
+			fsrs_difficulty = excluded.fsrs_difficulty,
+			status = excluded.status,
+			tags = excluded.tags,
+			section = excluded.section,
+			source = excluded.source,
+			type = excluded.type,
+			cloze_index = excluded.cloze_index,
+			source_quote = excluded.source_quote,
+			source_start = excluded.source_start,
+			source_end = excluded.source_end`,
+		card.ID, card.NoteID, card.Question, card.Answer, card.Difficulty, card.RepCount,
+		formatTime(card.LastReview), formatTime(card.NextReview),
+		card.EaseFactor, card.Interval, card.Repetitions, card.Lapses, card.Stability, card.FSRSDifficulty, card.Status, tags, section, card.Source, card.Type, card.ClozeIndex, card.SourceQuote,
+		card.SourceStart, card.SourceEnd)
+	if err != nil {
+		return fmt.Errorf("failed to save flashcard: %w", err)
+	}
+
+	return nil
+}
+
+// marshalTags encodes a list of tags or headings for a JSON text column
+func marshalTags(tags []string) (string, error) {
+	if tags == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(tags)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal list: %w", err)
+	}
+	return string(data), nil
+}
+
+// SaveFlashcard saves a flashcard to storage
+func (s *SQLiteStore) SaveFlashcard(card Flashcard) error {
+	if card.ID == "" {
+		card.ID = uuid.New().String()
+	}
+	return saveFlashcard(s.db, card)
+}
+
+// GetFlashcard retrieves a flashcard by ID
+func (s *SQLiteStore) GetFlashcard(id string) (Flashcard, error) {
+	card, err := scanFlashcard(s.db.QueryRow("SELECT "+cardColumns+" FROM flashcards WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Flashcard{}, fmt.Errorf("flashcard not found: %s", id)
+	}
+	if err != nil {
+		return Flashcard{}, fmt.Errorf("failed to get flashcard: %w", err)
+	}
+	return card, nil
+}
+
+// UpdateFlashcard updates an existing flashcard
+func (s *SQLiteStore) UpdateFlashcard(card Flashcard) error {
+	return s.SaveFlashcard(card)
+}
+
+// GetAllFlashcards retrieves all flashcards
+func (s *SQLiteStore) GetAllFlashcards() ([]Flashcard, error) {
+	return s.queryFlashcards("SELECT " + cardColumns + " FROM flashcards ORDER BY position")
+}
+
+// GetFlashcardsDueBefore returns all flashcards due before the given time
+func (s *SQLiteStore) GetFlashcardsDueBefore(t time.Time) ([]Flashcard, error) {
+	return s.queryFlashcards("SELECT "+cardColumns+" FROM flashcards WHERE status = ? AND next_review < ? ORDER BY position",
+		StatusActive, formatTime(t))
+}
+
+// DeleteFlashcard removes a flashcard by ID
+func (s *SQLiteStore) DeleteFlashcard(id string) error {
+	res, err := s.db.Exec("DELETE FROM flashcards WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete flashcard: %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("flashcard not found: %s", id)
+	}
+
+	return nil
+}
+
+// DeleteAllFlashcards removes all flashcards
+func (s *SQLiteStore) DeleteAllFlashcards() error {
+	if _, err := s.db.Exec("DELETE FROM flashcards"); err != nil {
+		return fmt.Errorf("failed to delete flashcards: %w", err)
+	}
+	return nil
+}
+
+const reviewColumns = "id, card_id, rating, reviewed_at, prev_interval_days, new_interval_days, response_ms"
+
+// addReview inserts a review into the review log
+func addReview(db execer, review Review) error {
+	_, err := db.Exec("INSERT INTO reviews ("+reviewColumns+") VALUES (?, ?, ?, ?, ?, ?, ?)",
+		review.ID, review.CardID, review.Rating, formatTime(review.ReviewedAt),
+		review.PrevInterval, review.NewInterval, review.ResponseMs)
+	if err != nil {
+		return fmt.Errorf("failed to save review: %w", err)
+	}
+	return nil
+}
+
+// queryReviews runs a query selecting reviewColumns and collects the results
+func (s *SQLiteStore) queryReviews(query string, args ...any) ([]Review, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reviews: %w", err)
+	}
+	defer rows.Close()
+
+	var reviews []Review
+	for rows.Next() {
+		var review Review
+		var reviewedAt string
+		if err := rows.Scan(&review.ID, &review.CardID, &review.Rating, &reviewedAt,
+			&review.PrevInterval, &review.NewInterval, &review.ResponseMs); err != nil {
+			return nil, fmt.Errorf("failed to read review: %w", err)
+		}
+		if review.ReviewedAt, err = parseTime(reviewedAt); err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, review)
+	}
+
+	return reviews, rows.Err()
+}
+
+// AddReview appends a review to the review log
+func (s *SQLiteStore) AddReview(review Review) error {
+	if review.ID == "" {
+		review.ID = uuid.New().String()
+	}
+	return addReview(s.db, review)
+}
+
+// GetReviews returns the review history of a single flashcard, oldest first
+func (s *SQLiteStore) GetReviews(cardID string) ([]Review, error) {
+	return s.queryReviews("SELECT "+reviewColumns+" FROM reviews WHERE card_id = ? ORDER BY reviewed_at, rowid", cardID)
+}
+
+// GetAllReviews returns the whole review history, oldest first
+func (s *SQLiteStore) GetAllReviews() ([]Review, error) {
+	return s.queryReviews("SELECT " + reviewColumns + " FROM reviews ORDER BY reviewed_at, rowid")
+}
+
+// fileExists reports whether path exists
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	dataDir = ".md-study"
+	dbFile  = "md-study.db"
+)
+
+// Names of the available storage backends
+const (
+	BackendJSON   = "json"
+	BackendSQLite = "sqlite"
+)
+
+// Store is implemented by every storage backend for notes, flashcards and review history
+type Store interface {
+	// SaveNote inserts a note or replaces the one with the same ID or file path
+	SaveNote(note Note) error
+	GetNote(id string) (Note, error)
+	GetAllNotes() ([]Note, error)
+	DeleteNote(id string) error
+
+	// SaveFlashcard inserts a flashcard or replaces the one with the same ID
+	SaveFlashcard(card Flashcard) error
+	GetFlashcard(id string) (Flashcard, error)
+	UpdateFlashcard(card Flashcard) error
+	GetAllFlashcards() ([]Flashcard, error)
+	// GetFlashcardsDueBefore returns active flashcards due before t
+	GetFlashcardsDueBefore(t time.Time) ([]Flashcard, error)
+	DeleteFlashcard(id string) error
+	DeleteAllFlashcards() error
+
+	// AddReview appends an entry to the review history
+	AddReview(review Review) error
+	GetReviews(cardID string) ([]Review, error)
+	GetAllReviews() ([]Review, error)
+
+	// Close releases any resources held by the backend
+	Close() error
+}
+
+// DefaultDir returns the directory md-study keeps its data in
+func DefaultDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, dataDir), nil
+}
+
+// Open opens the named storage backend in dir. The first time the SQLite
+// backend is opened, existing notes.json and flashcards.json are migrated into it.
+func Open(dir, backend string) (Store, error) {
+	switch backend {
+	case "", BackendJSON:
+		return NewJSONStore(dir)
+	case BackendSQLite:
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create storage directory: %w", err)
+		}
+
+		s, err := NewSQLiteStore(filepath.Join(dir, dbFile))
+		if err != nil {
+			return nil, err
+		}
+
+		src := &JSONStore{dir: dir}
+		if fileExists(src.path(notesFile)) && fileExists(src.path(cardsFile)) {
+			if _, err := s.ImportJSON(src); err != nil {
+				s.Close()
+				return nil, fmt.Errorf("failed to migrate JSON data: %w", err)
+			}
+		}
+
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", backend)
+	}
+}
+
+// GetStudyStats calculates and returns study statistics
+func GetStudyStats(s Store) (StudyStats, error) {
+	stats := StudyStats{}
+
+	notes, err := s.GetAllNotes()
+	if err != nil {
+		return stats, err
+	}
+
+	stats.TotalNotes = len(notes)
+
+	cards, err := s.GetAllFlashcards()
+	if err != nil {
+		return stats, err
+	}
+
+	stats.TotalFlashcards = len(cards)
+
+	// Cards due today include overdue ones; cards held out of study are never due
+	today := time.Now()
+	startOfDay := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, today.Location())
+	endOfDay := startOfDay.AddDate(0, 0, 1)
+
+	var dueToday, learned int
+
+	for _, card := range cards {
+		if card.Status == StatusActive && card.NextReview.Before(endOfDay) {
+			dueToday++
+		}
+
+		if card.RepCount > 0 {
+			learned++
+		}
+	}
+
+	stats.CardsDueToday = dueToday
+	stats.CardsLearned = learned
+
+	// Accuracy and timing come from the review log rather than each card's last rating
+	reviews, err := s.GetAllReviews()
+	if err != nil {
+		return stats, err
+	}
+
+	var recalled int
+	var totalResponseMs int64
+	for _, review := range reviews {
+		if review.Rating != RatingAgain {
+			recalled++
+		}
+		if !review.ReviewedAt.Before(startOfDay) {
+			stats.ReviewsToday++
+		}
+		totalResponseMs += review.ResponseMs
+	}
+
+	stats.TotalReviews = len(reviews)
+	if len(reviews) > 0 {
+		stats.ReviewAccuracy = 100 * float64(recalled) / float64(len(reviews))
+		stats.AvgResponseMs = totalResponseMs / int64(len(reviews))
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,322 @@
+package storage
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// backends opens an empty store of every kind, so each contract test runs
+// against all of them
+func backends(t *testing.T) map[string]Store {
+	t.Helper()
+
+	jsonStore, err := NewJSONStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	sqliteStore, err := Open(t.TempDir(), BackendSQLite)
+	if err != nil {
+		t.Fatalf("Open sqlite: %v", err)
+	}
+
+	stores := map[string]Store{
+		"memory":      NewMemoryStore(),
+		BackendJSON:   jsonStore,
+		BackendSQLite: sqliteStore,
+	}
+	for _, s := range stores {
+		t.Cleanup(func() { s.Close() })
+	}
+	return stores
+}
+
+func TestStoreNotes(t *testing.T) {
+	for name, s := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			note := Note{
+				ID:         "n1",
+				FilePath:   "/notes/go.md",
+				Filename:   "go.md",
+				Deck:       "lang",
+				Title:      "Go",
+				Tags:       []string{"go", "lang"},
+				RawContent: "# Go\n\nGo has goroutines.",
+				Flashcards: []string{},
+			}
+			if err := s.SaveNote(note); err != nil {
+				t.Fatalf("SaveNote: %v", err)
+			}
+
+			got, err := s.GetNote("n1")
+			if err != nil {
+				t.Fatalf("GetNote: %v", err)
+			}
+			if got.FilePath != note.FilePath || got.Deck != note.Deck || got.Title != note.Title ||
+				got.RawContent != note.RawContent || !slices.Equal(got.Tags, note.Tags) {
+				t.Errorf("GetNote = %+v, want %+v", got, note)
+			}
+
+			// A note saved again under the same path replaces the old one
+			moved := note
+			moved.ID = "n2"
+			moved.Title = "Go, again"
+			if err := s.SaveNote(moved); err != nil {
+				t.Fatalf("SaveNote: %v", err)
+			}
+			notes, err := s.GetAllNotes()
+			if err != nil {
+				t.Fatalf("GetAllNotes: %v", err)
+			}
+			if len(notes) != 1 || notes[0].ID != "n2" || notes[0].Title != "Go, again" {
+				t.Errorf("GetAllNotes = %+v, want only n2", notes)
+			}
+
+			if _, err := s.GetNote("n1"); err == nil {
+				t.Error("GetNote of a replaced note succeeded")
+			}
+			if err := s.DeleteNote("n2"); err != nil {
+				t.Fatalf("DeleteNote: %v", err)
+			}
+			if err := s.DeleteNote("n2"); err == nil {
+				t.Error("DeleteNote of a missing note succeeded")
+			}
+			if notes, _ := s.GetAllNotes(); len(notes) != 0 {
+				t.Errorf("GetAllNotes after delete = %d notes, want 0", len(notes))
+			}
+		})
+	}
+}
+
+func TestStoreFlashcards(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+
+	for name, s := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			// A card saved without its type, status, source or ease factor gets the defaults
+			if err := s.SaveFlashcard(Flashcard{ID: "c1", NoteID: "n1", Question: "Q1", Answer: "A1", NextReview: now.Add(-time.Hour)}); err != nil {
+				t.Fatalf("SaveFlashcard: %v", err)
+			}
+			got, err := s.GetFlashcard("c1")
+			if err != nil {
+				t.Fatalf("GetFlashcard: %v", err)
+			}
+			if got.Type != TypeBasic || got.Status != StatusActive || got.Source != SourceGenerated || got.EaseFactor != DefaultEaseFactor {
+				t.Errorf("defaults = %q %q %q %v, want %q %q %q %v", got.Type, got.Status, got.Source, got.EaseFactor,
+					TypeBasic, StatusActive, SourceGenerated, DefaultEaseFactor)
+			}
+			if !got.NextReview.Equal(now.Add(-time.Hour)) {
+				t.Errorf("NextReview = %v, want %v", got.NextReview, now.Add(-time.Hour))
+			}
+
+			cloze := Flashcard{
+				ID:             "c2",
+				NoteID:         "n1",
+				Type:           TypeCloze,
+				Question:       "{{c1::Paris}} is in France",
+				Answer:         "Paris",
+				ClozeIndex:     1,
+				Tags:           []string{"geo"},
+				Section:        []string{"Europe", "France"},
+				SourceQuote:    "Paris is in France",
+				SourceStart:    3,
+				SourceEnd:      4,
+				Stability:      4.5,
+				FSRSDifficulty: 6.2,
+				EaseFactor:     2.1,
+				Interval:       3,
+				Repetitions:    2,
+				Lapses:         1,
+				RepCount:       3,
+				LastReview:     now.Add(-72 * time.Hour),
+				NextReview:     now.Add(-time.Minute),
+			}
+			if err := s.SaveFlashcard(cloze); err != nil {
+				t.Fatalf("SaveFlashcard: %v", err)
+			}
+			got, err = s.GetFlashcard("c2")
+			if err != nil {
+				t.Fatalf("GetFlashcard: %v", err)
+			}
+			if got.Type != cloze.Type || got.ClozeIndex != cloze.ClozeIndex || !slices.Equal(got.Tags, cloze.Tags) ||
+				!slices.Equal(got.Section, cloze.Section) || got.SourceQuote != cloze.SourceQuote ||
+				got.SourceStart != cloze.SourceStart || got.SourceEnd != cloze.SourceEnd ||
+				got.Stability != cloze.Stability || got.FSRSDifficulty != cloze.FSRSDifficulty ||
+				got.EaseFactor != cloze.EaseFactor || got.Interval != cloze.Interval || got.Repetitions != cloze.Repetitions ||
+				got.Lapses != cloze.Lapses || got.RepCount != cloze.RepCount || !got.LastReview.Equal(cloze.LastReview) {
+				t.Errorf("GetFlashcard = %+v, want %+v", got, cloze)
+			}
+
+			// Updating keeps the card's place in listings
+			got.Answer = "Paris, the capital"
+			if err := s.UpdateFlashcard(got); err != nil {
+				t.Fatalf("UpdateFlashcard: %v", err)
+			}
+			if err := s.SaveFlashcard(Flashcard{ID: "c3", NoteID: "n1", Question: "Q3", Answer: "A3", NextReview: now.Add(time.Hour)}); err != nil {
+				t.Fatalf("SaveFlashcard: %v", err)
+			}
+			cards, err := s.GetAllFlashcards()
+			if err != nil {
+				t.Fatalf("GetAllFlashcards: %v", err)
+			}
+			if ids := cardIDs(cards); !slices.Equal(ids, []string{"c1", "c2", "c3"}) {
+				t.Errorf("GetAllFlashcards = %v, want [c1 c2 c3]", ids)
+			}
+			if cards[1].Answer != "Paris, the capital" {
+				t.Errorf("updated answer = %q", cards[1].Answer)
+			}
+
+			if err := s.DeleteFlashcard("c1"); err != nil {
+				t.Fatalf("DeleteFlashcard: %v", err)
+			}
+			if err := s.DeleteFlashcard("c1"); err == nil {
+				t.Error("DeleteFlashcard of a missing card succeeded")
+			}
+			if _, err := s.GetFlashcard("c1"); err == nil {
+				t.Error("GetFlashcard of a deleted card succeeded")
+			}
+
+			if err := s.DeleteAllFlashcards(); err != nil {
+				t.Fatalf("DeleteAllFlashcards: %v", err)
+			}
+			if cards, _ := s.GetAllFlashcards(); len(cards) != 0 {
+				t.Errorf("GetAllFlashcards after delete = %d cards, want 0", len(cards))
+			}
+		})
+	}
+}
+
+func TestStoreDueOnlyActive(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	past := now.Add(-time.Hour)
+
+	for name, s := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			statuses := []string{StatusActive, StatusOrphaned, StatusArchived, StatusUnverified, StatusPending}
+			for _, status := range statuses {
+				card := Flashcard{ID: status, NoteID: "n1", Question: status, Answer: status, Status: status, NextReview: past}
+				if err := s.SaveFlashcard(card); err != nil {
+					t.Fatalf("SaveFlashcard: %v", err)
+				}
+			}
+			if err := s.SaveFlashcard(Flashcard{ID: "later", NoteID: "n1", Question: "Q", Answer: "A", NextReview: now.Add(time.Hour)}); err != nil {
+				t.Fatalf("SaveFlashcard: %v", err)
+			}
+
+			for _, status := range statuses {
+				card, err := s.GetFlashcard(status)
+				if err != nil {
+					t.Fatalf("GetFlashcard: %v", err)
+				}
+				if card.Status != status {
+					t.Errorf("status = %q, want %q", card.Status, status)
+				}
+			}
+
+			due, err := s.GetFlashcardsDueBefore(now)
+			if err != nil {
+				t.Fatalf("GetFlashcardsDueBefore: %v", err)
+			}
+			if ids := cardIDs(due); !slices.Equal(ids, []string{StatusActive}) {
+				t.Errorf("GetFlashcardsDueBefore = %v, want [%s]", ids, StatusActive)
+			}
+		})
+	}
+}
+
+func TestStoreReviews(t *testing.T) {
+	start := time.Now().Truncate(time.Second).Add(-48 * time.Hour)
+
+	for name, s := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			reviews, err := s.GetAllReviews()
+			if err != nil {
+				t.Fatalf("GetAllReviews: %v", err)
+			}
+			if len(reviews) != 0 {
+				t.Fatalf("GetAllReviews of an empty store = %d reviews", len(reviews))
+			}
+
+			log := []Review{
+				{CardID: "c1", Rating: 1, ReviewedAt: start, NewInterval: 1, ResponseMs: 1200},
+				{CardID: "c2", Rating: 3, ReviewedAt: start.Add(time.Hour), NewInterval: 0.007, ResponseMs: 4000},
+				{CardID: "c1", Rating: 0, ReviewedAt: start.Add(24 * time.Hour), PrevInterval: 1, NewInterval: 4, ResponseMs: 800},
+			}
+			for _, review := range log {
+				if err := s.AddReview(review); err != nil {
+					t.Fatalf("AddReview: %v", err)
+				}
+			}
+
+			reviews, err = s.GetAllReviews()
+			if err != nil {
+				t.Fatalf("GetAllReviews: %v", err)
+			}
+			if len(reviews) != len(log) {
+				t.Fatalf("GetAllReviews = %d reviews, want %d", len(reviews), len(log))
+			}
+			for i, review := range reviews {
+				want := log[i]
+				if review.ID == "" {
+					t.Errorf("review %d has no ID", i)
+				}
+				if review.CardID != want.CardID || review.Rating != want.Rating || !review.ReviewedAt.Equal(want.ReviewedAt) ||
+					review.PrevInterval != want.PrevInterval || review.NewInterval != want.NewInterval || review.ResponseMs != want.ResponseMs {
+					t.Errorf("review %d = %+v, want %+v", i, review, want)
+				}
+			}
+
+			c1, err := s.GetReviews("c1")
+			if err != nil {
+				t.Fatalf("GetReviews: %v", err)
+			}
+			if len(c1) != 2 || c1[0].Rating != 1 || c1[1].Rating != 0 {
+				t.Errorf("GetReviews(c1) = %+v, want the two c1 reviews oldest first", c1)
+			}
+		})
+	}
+}
+
+// cardIDs lists the IDs of cards in order
+func cardIDs(cards []Flashcard) []string {
+	ids := make([]string, len(cards))
+	for i, card := range cards {
+		ids[i] = card.ID
+	}
+	return ids
+}
+
+func TestGetStudyStatsDueToday(t *testing.T) {
+	now := time.Now()
+	s := NewMemoryStore()
+
+	cards := []Flashcard{
+		{ID: "overdue", NextReview: now.AddDate(0, 0, -3)},
+		{ID: "now", NextReview: now},
+		{ID: "tomorrow", NextReview: now.AddDate(0, 0, 2)},
+		{ID: "pending", Status: StatusPending, NextReview: now},
+		{ID: "archived", Status: StatusArchived, NextReview: now.AddDate(0, 0, -1)},
+	}
+	for _, card := range cards {
+		if err := s.SaveFlashcard(card); err != nil {
+			t.Fatalf("SaveFlashcard: %v", err)
+		}
+	}
+	for _, rating := range []int{RatingGood, RatingAgain, RatingEasy, RatingHard} {
+		if err := s.AddReview(Review{CardID: "now", Rating: rating, ReviewedAt: now}); err != nil {
+			t.Fatalf("AddReview: %v", err)
+		}
+	}
+
+	stats, err := GetStudyStats(s)
+	if err != nil {
+		t.Fatalf("GetStudyStats: %v", err)
+	}
+	if stats.CardsDueToday != 2 {
+		t.Errorf("CardsDueToday = %d, want 2", stats.CardsDueToday)
+	}
+	if stats.TotalReviews != 4 || stats.ReviewsToday != 4 || stats.ReviewAccuracy != 75 {
+		t.Errorf("reviews = %d total, %d today, %.1f%% accurate, want 4, 4, 75%%",
+			stats.TotalReviews, stats.ReviewsToday, stats.ReviewAccuracy)
+	}
+}
@@ -5,15 +5,20 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/fatih/color"
+	"github.com/valdezdata/md-study/internal/cloze"
 	"github.com/valdezdata/md-study/internal/scheduler"
+	"github.com/valdezdata/md-study/internal/storage"
 )
 
 // StartStudySession begins an interactive study session
-func StartStudySession() {
+func StartStudySession(store storage.Store, schedulers scheduler.Set) {
+	clock := scheduler.SystemClock{}
+
 	// Get due flashcards
-	flashcards, err := scheduler.GetDueFlashcards()
+	flashcards, err := scheduler.GetDueFlashcards(store, schedulers, clock)
 	if err != nil {
 		fmt.Printf("Error getting flashcards: %v\n", err)
 		return
@@ -30,12 +35,28 @@ func StartStudySession() {
 
 	for i, card := range flashcards {
 		fmt.Printf("\n--- Card %d/%d ---\n", i+1, len(flashcards))
-		color.Cyan("%s", card.Question)
+		if len(card.Section) > 0 {
+			color.New(color.Faint).Println(strings.Join(card.Section, " > "))
+		}
+		if card.Type == storage.TypeCloze {
+			color.Cyan("%s", cloze.Blank(card.Question, card.ClozeIndex))
+		} else {
+			color.Cyan("%s", card.Question)
+		}
+		shownAt := time.Now()
 
 		fmt.Print("\nPress Enter to see answer...")
 		reader.ReadString('\n')
+		responseTime := time.Since(shownAt)
 
-		color.Yellow("%s", card.Answer)
+		if card.Type == storage.TypeCloze {
+			// The full text, with what was hidden picked out
+			fmt.Println(cloze.Reveal(card.Question, card.ClozeIndex, func(s string) string {
+				return color.New(color.FgYellow, color.Bold).Sprint(s)
+			}))
+		} else {
+			color.Yellow("%s", card.Answer)
+		}
 
 		fmt.Println("\nRate your recall:")
 		color.Green("1 - Easy")
@@ -50,19 +71,21 @@ func StartStudySession() {
 		var difficulty int
 		switch input {
 		case "1":
-			difficulty = 0 // Easy
+			difficulty = scheduler.Easy
 		case "2":
-			difficulty = 1 // Good
+			difficulty = scheduler.Good
 		case "3":
-			difficulty = 2 // Hard
+			difficulty = scheduler.Hard
 		case "4":
-			difficulty = 3 // Again
+			difficulty = scheduler.Again
 		default:
-			difficulty = 2 // Default to Hard if invalid input
+			difficulty = scheduler.Hard // Default to Hard if invalid input
 		}
 
 		// Update card difficulty and next review time
-		scheduler.UpdateFlashcard(card.ID, difficulty)
+		if err := scheduler.UpdateFlashcard(store, schedulers, clock, card.ID, difficulty, responseTime); err != nil {
+			fmt.Printf("Error updating flashcard: %v\n", err)
+		}
 	}
 
 	fmt.Println("\nStudy session complete!")